@@ -21,10 +21,15 @@ type configKey struct {
 
 var (
 	registry           = configKey{name: "registry", description: "Registry to push built containers to (e.g. docker.io/foo, foo.azurecr.io)"}
-	containerBuilder   = configKey{name: "container-builder", description: "How to build the container (supported values: docker, acrbuild)"}
+	containerBuilder   = configKey{name: "container-builder", description: "How to build the container (supported values: docker, acrbuild, buildkit, incluster)"}
 	resourceGroupName  = configKey{name: "resource-group-name", description: "The Azure resource group of the container registry (for Azure registries only)"}
 	disablePushWarning = configKey{name: "disable-push-warning", description: "Suppresses warning if no registry set"}
-	configKeys         = []configKey{registry, containerBuilder, resourceGroupName, disablePushWarning}
+	storageEngine      = configKey{name: "storage-engine", description: "Where to store the build history (supported values: configmap, secret, filesystem)"}
+	historyMaxBuilds   = configKey{name: "history-max-builds", description: "Number of most recent builds to keep in the build history of each app"}
+	historyMaxAge      = configKey{name: "history-max-age", description: "How long builds are kept in the build history (e.g. 720h)"}
+	releaseBackend     = configKey{name: "release-backend", description: "How to release the chart when draft.toml does not say (supported values: tiller, tillerless)"}
+	storageNamespace   = configKey{name: "storage-namespace", description: "Namespace the configmap and secret storage engines keep the build history in (defaults to the Tiller namespace)"}
+	configKeys         = []configKey{registry, containerBuilder, resourceGroupName, disablePushWarning, storageEngine, historyMaxBuilds, historyMaxAge, releaseBackend, storageNamespace}
 )
 
 // DraftConfig is the configuration stored in $DRAFT_HOME/config.toml
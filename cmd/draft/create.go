@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/ignore"
 	"github.com/Azure/draft/pkg/draft/manifest"
 	"github.com/Azure/draft/pkg/draft/pack"
 	"github.com/Azure/draft/pkg/draft/pack/repo"
@@ -131,7 +132,7 @@ func (c *createCmd) run() error {
 		return fmt.Errorf("could not write metadata to draft.toml: %v", err)
 	}
 
-	ignoreFile := filepath.Join(c.dest, ignoreFileName)
+	ignoreFile := filepath.Join(c.dest, ignore.DraftIgnore)
 	if _, err := os.Stat(ignoreFile); os.IsNotExist(err) {
 		d1 := []byte("*.swp\n*.tmp\n*.temp\n.git*\n")
 		if err := ioutil.WriteFile(ignoreFile, d1, 0644); err != nil {
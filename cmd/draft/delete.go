@@ -7,11 +7,12 @@ import (
 
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
-	"k8s.io/helm/pkg/helm"
 
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/kube/apply"
 	"github.com/Azure/draft/pkg/local"
-	"github.com/Azure/draft/pkg/storage/kube/configmap"
 	"github.com/Azure/draft/pkg/tasks"
 )
 
@@ -48,56 +49,61 @@ func newDeleteCmd(out io.Writer) *cobra.Command {
 }
 
 func (d *deleteCmd) run(runningEnvironment string) error {
-
-	var name string
-
-	if d.appName != "" {
-		name = d.appName
-	} else {
-		deployedApp, err := local.DeployedApplication(draftToml, runningEnvironment)
-		if err != nil {
-			return errors.New("Unable to detect app name\nPlease pass in the name of the application")
-
+	// the environment in draft.toml tells where and how the app was released, unless
+	// another app was named.
+	env := &manifest.Environment{Name: d.appName, Namespace: manifest.DefaultNamespace}
+	if mfst, err := manifest.Load(draftToml); err == nil {
+		if e, ok := mfst.Environments[runningEnvironment]; ok && (d.appName == "" || d.appName == e.Name) {
+			env = e
 		}
-
-		name = deployedApp.Name
+	}
+	if env.Name == "" {
+		return errors.New("Unable to detect app name\nPlease pass in the name of the application")
 	}
 
 	//TODO: replace with serverside call
-	if err := Delete(name); err != nil {
+	if err := Delete(env); err != nil {
 		return err
 	}
 
-	msg := "app '" + name + "' deleted"
+	msg := "app '" + env.Name + "' deleted"
 	fmt.Fprintln(d.out, msg)
 	return nil
 }
 
-// Delete uses the helm client to delete an app with the given name
+// Delete deletes the release of the app of env with the configured release backend, along
+// with its build history.
 //
 // Returns an error if the command failed.
-func Delete(app string) error {
-	// set up helm client
-	client, config, err := getKubeClient(kubeContext)
-	if err != nil {
-		return fmt.Errorf("Could not get a kube client: %s", err)
-	}
-
+func Delete(env *manifest.Environment) error {
 	// delete Draft storage for app
-	store := configmap.NewConfigMaps(client.CoreV1().ConfigMaps(tillerNamespace))
-	if _, err := store.DeleteBuilds(context.Background(), app); err != nil {
+	store, err := newStorage(configuredStorageEngine(), draftpath.Home(homePath()))
+	if err != nil {
 		return err
 	}
-
-	helmClient, err := setupHelm(client, config, tillerNamespace)
-	if err != nil {
+	if _, err := store.DeleteBuilds(context.Background(), env.Name); err != nil {
 		return err
 	}
 
-	// delete helm release
-	_, err = helmClient.DeleteRelease(app, helm.DeletePurge(true))
-	if err != nil {
-		return errors.New(grpc.ErrorDesc(err))
+	if builder.DeploysObjects(env) {
+		// delete the objects applied from plain manifests or a kustomization, tracked by label
+		client, _, err := getKubeClient(kubeContext)
+		if err != nil {
+			return fmt.Errorf("Could not get a kube client: %s", err)
+		}
+		if err := apply.New(client).Delete(env.Namespace, local.DraftLabelKey+"="+env.Name); err != nil {
+			return err
+		}
+	} else {
+		backend, err := newReleaseBackend(env)
+		if err != nil {
+			return err
+		}
+
+		// delete the release
+		if err := backend.Delete(context.Background(), env); err != nil {
+			return err
+		}
 	}
 
 	taskList, err := tasks.Load(tasksTOMLFile)
@@ -90,6 +90,7 @@ func newRootCmd(out io.Writer, in io.Reader) *cobra.Command {
 		newDeleteCmd(out),
 		newLogsCmd(out),
 		newHistoryCmd(out),
+		newRollbackCmd(out),
 		newPackCmd(out),
 	)
 
@@ -133,6 +134,18 @@ func configForContext(context string) (clientcmd.ClientConfig, *rest.Config, err
 	return clientConfig, config, nil
 }
 
+// kubeContextName returns the name of the given kubeconfig context, or of the current context if empty.
+func kubeContextName(context string) (string, error) {
+	if context != "" {
+		return context, nil
+	}
+	config, err := kube.GetConfig(context).RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("could not get Kubernetes config: %s", err)
+	}
+	return config.CurrentContext, nil
+}
+
 // getKubeClient creates a Kubernetes config and client for a given kubeconfig context.
 func getKubeClient(context string) (kubernetes.Interface, *rest.Config, error) {
 	_, config, err := configForContext(context)
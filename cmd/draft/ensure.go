@@ -18,6 +18,7 @@ func (i *initCmd) ensureDirectories() error {
 		i.home.Plugins(),
 		i.home.Packs(),
 		i.home.Logs(),
+		i.home.Storage(),
 	}
 	for _, p := range configDirectories {
 		err := osutil.EnsureDirectory(p)
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/Azure/draft/pkg/draft/draftpath"
 	"github.com/Azure/draft/pkg/local"
 	"github.com/Azure/draft/pkg/storage"
-	"github.com/Azure/draft/pkg/storage/kube/configmap"
 	"github.com/ghodss/yaml"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
 	"io"
 	"k8s.io/helm/pkg/timeconv"
+	"strings"
+	"time"
 )
 
 const historyDesc = `Display the build history of a Draft application.`
@@ -37,6 +40,11 @@ func newHistoryCmd(out io.Writer) *cobra.Command {
 		},
 	}
 
+	cmd.AddCommand(
+		newHistoryMigrateCmd(out),
+		newHistoryPruneCmd(out),
+	)
+
 	f := cmd.Flags()
 	f.Int64Var(&hc.max, "max", 256, "maximum number of results to include in history")
 	f.UintVar(&hc.colWidth, "col-width", 60, "specifies the max column width of output")
@@ -51,11 +59,10 @@ func (cmd *historyCmd) run() error {
 	if err != nil {
 		return err
 	}
-	client, _, err := getKubeClient(kubeContext)
+	store, err := newStorage(configuredStorageEngine(), draftpath.Home(homePath()))
 	if err != nil {
-		return fmt.Errorf("Could not get a kube client: %v", err)
+		return err
 	}
-	store := configmap.NewConfigMaps(client.CoreV1().ConfigMaps(tillerNamespace))
 
 	// get history from store
 	h, err := getHistory(context.Background(), store, app.Name, cmd.max)
@@ -109,27 +116,74 @@ func getHistory(ctx context.Context, store storage.Store, app string, max int64)
 type buildHistory []buildInfo
 
 type buildInfo struct {
-	BuildID string `json:"buildID"`
-	Release string `json:"release"`
-	Context string `json:"context"`
-	Created string `json:"createdAt"`
+	BuildID       string      `json:"buildID"`
+	Release       string      `json:"release"`
+	Context       string      `json:"context"`
+	Created       string      `json:"createdAt"`
+	Environment   string      `json:"environment,omitempty"`
+	Images        []string    `json:"images,omitempty"`
+	Revision      string      `json:"vcsRevision,omitempty"`
+	Dirty         bool        `json:"vcsDirty,omitempty"`
+	Status        string      `json:"status,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	FailedStage   string      `json:"failedStage,omitempty"`
+	ReleaseRev    int32       `json:"releaseRevision,omitempty"`
+	RollbackTo    string      `json:"rollbackTo,omitempty"`
+	ImageArchive  string      `json:"imageArchive,omitempty"`
+	ArchiveDigest string      `json:"imageArchiveDigest,omitempty"`
+	ImageDigest   string      `json:"imageDigest,omitempty"`
+	Duration      string      `json:"duration,omitempty"`
+	Stages        []stageInfo `json:"stages,omitempty"`
+}
+
+type stageInfo struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
 }
 
 func toBuildHistory(ls []*storage.Object) (h buildHistory) {
-	orElse := func(str, def string) string {
-		if str != "" {
-			return str
-		}
-		return def
-	}
 	for i := len(ls) - 1; i >= 0; i-- {
 		rls := orElse(ls[i].GetRelease(), "-")
-		ctx := ls[i].GetContextID()
+		var (
+			stages []stageInfo
+			total  time.Duration
+		)
+		for _, stage := range ls[i].GetStages() {
+			d, err := ptypes.Duration(stage.GetDuration())
+			if err != nil {
+				d = 0
+			}
+			total += d
+			stages = append(stages, stageInfo{
+				Name:     stage.GetName(),
+				Status:   stage.GetStatus(),
+				Duration: d.String(),
+			})
+		}
+		var duration string
+		if len(stages) > 0 {
+			duration = total.String()
+		}
 		h = append(h, buildInfo{
-			BuildID: ls[i].GetBuildID(),
-			Release: rls,
-			Context: fmt.Sprintf("%X", ctx[len(ctx)-5:]),
-			Created: timeconv.String(ls[i].GetCreatedAt()),
+			BuildID:       ls[i].GetBuildID(),
+			Release:       rls,
+			Context:       contextID(ls[i].GetContextID()),
+			Created:       timeconv.String(ls[i].GetCreatedAt()),
+			Environment:   ls[i].GetEnvironment(),
+			Images:        ls[i].GetImages(),
+			Revision:      ls[i].GetVcsRevision(),
+			Dirty:         ls[i].GetVcsDirty(),
+			Status:        ls[i].GetStatus(),
+			Error:         ls[i].GetErrorMessage(),
+			FailedStage:   ls[i].GetFailedStage(),
+			ReleaseRev:    ls[i].GetReleaseVersion(),
+			RollbackTo:    ls[i].GetRollbackTo(),
+			ImageArchive:  ls[i].GetImageArchiveRef(),
+			ArchiveDigest: ls[i].GetImageArchiveDigest(),
+			ImageDigest:   ls[i].GetImageDigest(),
+			Duration:      duration,
+			Stages:        stages,
 		})
 	}
 	return h
@@ -138,10 +192,44 @@ func toBuildHistory(ls []*storage.Object) (h buildHistory) {
 func formatTable(h buildHistory, w uint) []byte {
 	tbl := uitable.New()
 	tbl.MaxColWidth = w
-	tbl.AddRow("BUILD_ID", "CONTEXT_ID", "CREATED_AT", "RELEASE")
+	tbl.AddRow("BUILD_ID", "CONTEXT_ID", "CREATED_AT", "RELEASE", "ENVIRONMENT", "REVISION", "IMAGE", "DURATION", "STATUS")
 	for i := 0; i < len(h); i++ {
 		b := h[i]
-		tbl.AddRow(b.BuildID, b.Context, b.Created, b.Release)
+		revision := b.Revision
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+		if revision != "" && b.Dirty {
+			revision += "-dirty"
+		}
+		var image string
+		if len(b.Images) > 0 {
+			image = b.Images[0]
+		}
+		tbl.AddRow(b.BuildID, b.Context, b.Created, b.Release,
+			orElse(b.Environment, "-"),
+			orElse(revision, "-"),
+			orElse(image, "-"),
+			orElse(b.Duration, "-"),
+			orElse(strings.ToUpper(b.Status), "-"),
+		)
 	}
 	return tbl.Bytes()
 }
+
+// contextID returns the abbreviated checksum of a build's docker context, or "-" for
+// builds which failed before their context was archived.
+func contextID(sum []byte) string {
+	if len(sum) < 5 {
+		return "-"
+	}
+	return fmt.Sprintf("%X", sum[len(sum)-5:])
+}
+
+// orElse returns str, or def if str is empty.
+func orElse(str, def string) string {
+	if str != "" {
+		return str
+	}
+	return def
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Azure/draft/pkg/local"
+	"github.com/Azure/draft/pkg/storage/kube/configmap"
+	"github.com/Azure/draft/pkg/storage/kube/secret"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+)
+
+const historyMigrateDesc = `Copy the build history of a Draft application from configmaps into secrets.
+
+Build records stored in configmaps are readable by anyone with read access to the
+storage namespace. After migrating, set the storage engine to secrets with
+
+	$ draft config set storage-engine secret
+
+Builds which already exist in the secret storage are left untouched. Pass --purge to
+delete the configmap once its history has been copied.
+`
+
+type historyMigrateCmd struct {
+	out   io.Writer
+	env   string
+	purge bool
+}
+
+func newHistoryMigrateCmd(out io.Writer) *cobra.Command {
+	mc := &historyMigrateCmd{out: out}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "copy build history from configmaps into secrets",
+		Long:  historyMigrateDesc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return mc.run()
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&mc.purge, "purge", false, "delete the configmap build history once it has been migrated")
+	f.StringVarP(&mc.env, environmentFlagName, environmentFlagShorthand, defaultDraftEnvironment(), environmentFlagUsage)
+	return cmd
+}
+
+func (cmd *historyMigrateCmd) run() error {
+	app, err := local.DeployedApplication(draftToml, cmd.env)
+	if err != nil {
+		return err
+	}
+	client, _, err := getKubeClient(kubeContext)
+	if err != nil {
+		return fmt.Errorf("Could not get a kube client: %v", err)
+	}
+	var (
+		ctx  = context.Background()
+		from = configmap.NewConfigMaps(client.CoreV1().ConfigMaps(configuredStorageNamespace()))
+		to   = secret.NewSecrets(client.CoreV1().Secrets(configuredStorageNamespace()))
+	)
+	builds, err := from.GetBuilds(ctx, app.Name)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve application (%q) build history from configmaps: %v", app.Name, err)
+	}
+	if err := to.ImportBuilds(ctx, app.Name, builds); err != nil {
+		return fmt.Errorf("failed to migrate application (%q) build history to secrets: %v", app.Name, err)
+	}
+	fmt.Fprintf(cmd.out, "Migrated %d builds of %s to secrets\n", len(builds), app.Name)
+
+	if cmd.purge {
+		if _, err := from.DeleteBuilds(ctx, app.Name); err != nil {
+			return fmt.Errorf("failed to delete application (%q) build history from configmaps: %v", app.Name, err)
+		}
+		fmt.Fprintf(cmd.out, "Deleted configmap build history of %s\n", app.Name)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/manifest"
+)
+
+const historyPruneDesc = `Delete old builds from the build history of a Draft application.
+
+Builds beyond the retention limits are deleted from the storage engine along with
+their log files and the image archives no retained build refers to. The limits are read from the history-max-builds and history-max-age
+settings of the environment in draft.toml, falling back to the ones set with
+'draft config set'. Flags override both.
+`
+
+type historyPruneCmd struct {
+	out       io.Writer
+	env       string
+	maxBuilds int
+	maxAge    time.Duration
+}
+
+func newHistoryPruneCmd(out io.Writer) *cobra.Command {
+	pc := &historyPruneCmd{out: out}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "delete old builds from the build history",
+		Long:  historyPruneDesc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pc.run()
+		},
+	}
+
+	f := cmd.Flags()
+	f.IntVar(&pc.maxBuilds, "max-builds", 0, "number of most recent builds to keep")
+	f.DurationVar(&pc.maxAge, "max-age", 0, "how long builds are kept (e.g. 720h)")
+	f.StringVarP(&pc.env, environmentFlagName, environmentFlagShorthand, defaultDraftEnvironment(), environmentFlagUsage)
+	return cmd
+}
+
+func (cmd *historyPruneCmd) run() error {
+	mfst, err := manifest.Load(draftToml)
+	if err != nil {
+		return err
+	}
+	env, ok := mfst.Environments[cmd.env]
+	if !ok {
+		return fmt.Errorf("no environment named %q in draft.toml", cmd.env)
+	}
+
+	bldr := builder.New()
+	if bldr.Storage, err = newStorage(configuredStorageEngine(), draftpath.Home(homePath())); err != nil {
+		return fmt.Errorf("Could not set up the storage engine: %v", err)
+	}
+	if bldr.Retention, err = retentionPolicy(env); err != nil {
+		return err
+	}
+	if cmd.maxBuilds > 0 {
+		bldr.Retention.MaxBuilds = cmd.maxBuilds
+	}
+	if cmd.maxAge > 0 {
+		bldr.Retention.MaxAge = cmd.maxAge
+	}
+	if bldr.Retention.MaxBuilds <= 0 && bldr.Retention.MaxAge <= 0 {
+		return fmt.Errorf("no retention limits set; pass --max-builds or --max-age, or set %s or %s", historyMaxBuilds.name, historyMaxAge.name)
+	}
+
+	pruned, err := bldr.Prune(context.Background(), env.Name)
+	if err != nil {
+		return fmt.Errorf("failed to prune application (%q) build history: %v", env.Name, err)
+	}
+	fmt.Fprintf(cmd.out, "Pruned %d builds of %s\n", len(pruned), env.Name)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/builder/tiller"
+	"github.com/Azure/draft/pkg/builder/tillerless"
+	"github.com/Azure/draft/pkg/draft/manifest"
+)
+
+const (
+	// tillerReleaseBackend releases charts through Tiller.
+	tillerReleaseBackend = "tiller"
+	// tillerlessReleaseBackend renders charts client-side and keeps the release history in
+	// the namespace of each release.
+	tillerlessReleaseBackend = "tillerless"
+)
+
+// configuredReleaseBackend returns the release backend of env, falling back to the one set in
+// $DRAFT_HOME/config.toml and then to Tiller.
+func configuredReleaseBackend(env *manifest.Environment) string {
+	if env.ReleaseBackend != "" {
+		return env.ReleaseBackend
+	}
+	if backend, ok := globalConfig[releaseBackend.name]; ok && backend != "" {
+		return backend
+	}
+	return tillerReleaseBackend
+}
+
+// newReleaseBackend returns the release backend draft should use to release env.
+func newReleaseBackend(env *manifest.Environment) (builder.ReleaseBackend, error) {
+	switch backend := configuredReleaseBackend(env); backend {
+	case tillerReleaseBackend:
+		client, config, err := getKubeClient(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a kube client: %s", err)
+		}
+		helmClient, err := setupHelm(client, config, tillerNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a helm client: %s", err)
+		}
+		return &tiller.Backend{Helm: helmClient}, nil
+	case tillerlessReleaseBackend:
+		clientConfig, _, err := configForContext(kubeContext)
+		if err != nil {
+			return nil, err
+		}
+		client, _, err := getKubeClient(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a kube client: %s", err)
+		}
+		return tillerless.New(clientConfig, client), nil
+	default:
+		return nil, fmt.Errorf("unknown release backend %q (supported values: %s, %s)", backend, tillerReleaseBackend, tillerlessReleaseBackend)
+	}
+}
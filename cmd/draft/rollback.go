@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/manifest"
+)
+
+const rollbackDesc = `Redeploy a previous build of a Draft application without rebuilding it.
+
+If no build ID is given, the application is rolled back to the latest successful
+build before the most recent one. Build IDs are listed by 'draft history'.
+
+The rollback itself is recorded in the build history.
+`
+
+type rollbackCmd struct {
+	out     io.Writer
+	env     string
+	buildID string
+}
+
+func newRollbackCmd(out io.Writer) *cobra.Command {
+	rc := &rollbackCmd{out: out}
+	cmd := &cobra.Command{
+		Use:   "rollback [build-id]",
+		Short: "redeploy a previous build of an application",
+		Long:  rollbackDesc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				rc.buildID = args[0]
+			}
+			return rc.run()
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&rc.env, environmentFlagName, environmentFlagShorthand, defaultDraftEnvironment(), environmentFlagUsage)
+	return cmd
+}
+
+func (cmd *rollbackCmd) run() error {
+	mfst, err := manifest.Load(draftToml)
+	if err != nil {
+		return err
+	}
+	env, ok := mfst.Environments[cmd.env]
+	if !ok {
+		return fmt.Errorf("no environment named %q in draft.toml", cmd.env)
+	}
+
+	bldr := builder.New()
+	if bldr.Storage, err = newStorage(configuredStorageEngine(), draftpath.Home(homePath())); err != nil {
+		return fmt.Errorf("Could not set up the storage engine: %v", err)
+	}
+	if bldr.Retention, err = retentionPolicy(env); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	builds, err := bldr.Storage.GetBuilds(ctx, env.Name)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve application (%q) build history from storage: %v", env.Name, err)
+	}
+	target, err := builder.RollbackTarget(builds, cmd.buildID)
+	if err != nil {
+		return err
+	}
+
+	if bldr.ReleaseBackend, err = newReleaseBackend(env); err != nil {
+		return err
+	}
+
+	obj, err := bldr.Rollback(ctx, env, target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.out, "Rolled back %s to build %s (revision %d)\n", env.Name, target.GetBuildID(), obj.GetReleaseVersion())
+	return nil
+}
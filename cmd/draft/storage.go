@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/Azure/draft/pkg/storage/filesystem"
+	"github.com/Azure/draft/pkg/storage/kube/configmap"
+	"github.com/Azure/draft/pkg/storage/kube/secret"
+)
+
+const (
+	// configMapStorageEngine stores build history in configmaps in the storage namespace.
+	configMapStorageEngine = "configmap"
+	// secretStorageEngine stores build history in secrets in the storage namespace.
+	secretStorageEngine = "secret"
+	// filesystemStorageEngine stores build history under $DRAFT_HOME.
+	filesystemStorageEngine = "filesystem"
+)
+
+// configuredStorageEngine returns the storage engine set in $DRAFT_HOME/config.toml,
+// defaulting to configmaps.
+func configuredStorageEngine() string {
+	if engine, ok := globalConfig[storageEngine.name]; ok && engine != "" {
+		return engine
+	}
+	return configMapStorageEngine
+}
+
+// configuredStorageNamespace returns the namespace set in $DRAFT_HOME/config.toml for the
+// storage engines backed by the cluster, defaulting to the Tiller namespace.
+func configuredStorageNamespace() string {
+	if namespace, ok := globalConfig[storageNamespace.name]; ok && namespace != "" {
+		return namespace
+	}
+	return tillerNamespace
+}
+
+// newStorage returns the storage engine draft should use for storing builds.
+//
+// Only storage engines backed by the cluster require a connection to Kubernetes.
+func newStorage(engine string, home draftpath.Home) (storage.Store, error) {
+	switch engine {
+	case configMapStorageEngine:
+		client, _, err := getKubeClient(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a kube client: %v", err)
+		}
+		return configmap.NewConfigMaps(client.CoreV1().ConfigMaps(configuredStorageNamespace())), nil
+	case secretStorageEngine:
+		client, _, err := getKubeClient(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("Could not get a kube client: %v", err)
+		}
+		return secret.NewSecrets(client.CoreV1().Secrets(configuredStorageNamespace())), nil
+	case filesystemStorageEngine:
+		return filesystem.NewStore(home.Storage()), nil
+	default:
+		return nil, fmt.Errorf("unknown storage engine %q (supported values: %s, %s, %s)", engine, configMapStorageEngine, secretStorageEngine, filesystemStorageEngine)
+	}
+}
+
+// retentionPolicy returns the build history retention policy for env. Limits set on the
+// environment in draft.toml take precedence over the ones in $DRAFT_HOME/config.toml.
+func retentionPolicy(env *manifest.Environment) (policy storage.RetentionPolicy, err error) {
+	maxBuilds, maxAge := globalConfig[historyMaxBuilds.name], globalConfig[historyMaxAge.name]
+	if env != nil {
+		if env.HistoryMaxBuilds > 0 {
+			maxBuilds = strconv.Itoa(env.HistoryMaxBuilds)
+		}
+		if env.HistoryMaxAge != "" {
+			maxAge = env.HistoryMaxAge
+		}
+	}
+	if maxBuilds != "" {
+		if policy.MaxBuilds, err = strconv.Atoi(maxBuilds); err != nil {
+			return policy, fmt.Errorf("invalid %s %q: %v", historyMaxBuilds.name, maxBuilds, err)
+		}
+	}
+	if maxAge != "" {
+		if policy.MaxAge, err = time.ParseDuration(maxAge); err != nil {
+			return policy, fmt.Errorf("invalid %s %q: %v", historyMaxAge.name, maxAge, err)
+		}
+	}
+	return policy, nil
+}
@@ -6,7 +6,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/Azure/go-autorest/autorest"
 	azurecli "github.com/Azure/go-autorest/autorest/azure/cli"
@@ -25,21 +27,27 @@ import (
 	"github.com/Azure/draft/pkg/azure/iam"
 	"github.com/Azure/draft/pkg/builder"
 	azurecontainerbuilder "github.com/Azure/draft/pkg/builder/azure"
+	buildkitcontainerbuilder "github.com/Azure/draft/pkg/builder/buildkit"
 	dockercontainerbuilder "github.com/Azure/draft/pkg/builder/docker"
+	inclustercontainerbuilder "github.com/Azure/draft/pkg/builder/incluster"
+	"github.com/Azure/draft/pkg/builder/localcluster"
 	"github.com/Azure/draft/pkg/cmdline"
 	"github.com/Azure/draft/pkg/draft/draftpath"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/kube/apply"
 	"github.com/Azure/draft/pkg/local"
-	"github.com/Azure/draft/pkg/storage/kube/configmap"
 	"github.com/Azure/draft/pkg/tasks"
 )
 
+// errUpInterrupted is returned when draft up is interrupted by a signal.
+var errUpInterrupted = errors.New("draft up was interrupted")
+
 const upDesc = `
 This command builds a container image using Docker, pushes it to a container registry
 and then instructs helm to install the chart, referencing the image just built.
 `
 
 const (
-	ignoreFileName        = ".draftignore"
 	dockerTLSEnvVar       = "DOCKER_TLS"
 	dockerTLSVerifyEnvVar = "DOCKER_TLS_VERIFY"
 	tasksTOMLFile         = ".draft-tasks.toml"
@@ -50,6 +58,9 @@ var (
 	autoConnect    bool
 	skipImagePush  bool
 	quiet          bool
+	watch          bool
+	verbose        bool
+	chartOnly      bool
 )
 
 type upCmd struct {
@@ -58,6 +69,10 @@ type upCmd struct {
 	home draftpath.Home
 	// storage engine draft should use for storing builds, logs, etc.
 	storageEngine string
+	// output format of the build progress (text or json).
+	output string
+	// dryRun prints what would be released instead of building and releasing it.
+	dryRun bool
 	// options common to the docker client and the daemon.
 	dockerClientOptions *dockerflags.ClientOptions
 }
@@ -111,6 +126,7 @@ func newUpCmd(out io.Writer) *cobra.Command {
 				}
 			}
 			up.home = draftpath.Home(homePath())
+			up.storageEngine = configuredStorageEngine()
 			return up.run(runningEnvironment)
 		},
 	}
@@ -125,6 +141,12 @@ func newUpCmd(out io.Writer) *cobra.Command {
 	f.BoolVarP(&autoConnect, "auto-connect", "", false, "specifies if draft up should automatically connect to the application")
 	f.BoolVar(&skipImagePush, "skip-image-push", false, "skip pushing image to registry")
 	f.BoolVarP(&quiet, "quiet", "q", false, "only output errors")
+	f.BoolVar(&verbose, "verbose", false, "stream the image build output while building")
+	f.BoolVar(&verbose, "follow-build", false, "alias for --verbose")
+	f.BoolVarP(&watch, "watch", "w", false, "watch for changes to the application and redeploy on every change")
+	f.BoolVar(&chartOnly, "chart-only", false, "deploy the chart with the image of the latest successful build instead of building a new one")
+	f.BoolVar(&up.dryRun, "dry-run", false, "print the values and the manifest which would be released instead of building and deploying the application")
+	f.StringVarP(&up.output, "output", "o", "text", "prints the build progress in the specified format (json|text)")
 
 	up.dockerClientOptions.Common.TLSOptions = &tlsconfig.Options{
 		CAFile:   filepath.Join(dockerCertPath, dockerflags.DefaultCaFile),
@@ -147,11 +169,19 @@ func (u *upCmd) run(environment string) (err error) {
 	var (
 		buildctx   *builder.Context
 		kubeConfig *rest.Config
-		ctx        = context.Background()
 		bldr       = builder.New()
 	)
 	bldr.LogsDir = u.home.Logs()
 
+	ctx, cancel := cancelOnInterrupt(context.Background())
+	defer cancel()
+
+	switch u.output {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown output format %q", u.output)
+	}
+
 	taskList, err := tasks.Load(tasksTOMLFile)
 	if err != nil {
 		if err == tasks.ErrNoTaskFile {
@@ -159,7 +189,8 @@ func (u *upCmd) run(environment string) (err error) {
 		} else {
 			return err
 		}
-	} else {
+	} else if !u.dryRun {
+		// a dry run must not change anything, so the pre-up tasks are not run either.
 		if _, err = taskList.Run(tasks.DefaultRunner, tasks.PreUp, ""); err != nil {
 			return err
 		}
@@ -168,33 +199,39 @@ func (u *upCmd) run(environment string) (err error) {
 	if buildctx, err = builder.LoadWithEnv(u.src, environment); err != nil {
 		return fmt.Errorf("failed loading build context with env %q: %v", environment, err)
 	}
+	defer buildctx.Close()
 
-	if configuredBuilder, ok := globalConfig[containerBuilder.name]; ok {
-		buildctx.Env.ContainerBuilder = configuredBuilder
-	}
-
-	// if a registry has been set in their global config but nothing was in draft.toml, use that instead.
-	if reg, ok := globalConfig[registry.name]; ok {
-		buildctx.Env.Registry = reg
-	}
+	u.configureEnv(buildctx.Env)
+	buildctx.ChartOnly = chartOnly
 
-	// Check if skip-image-push is specified. If so, unset registry.
-	if skipImagePush {
-		buildctx.Env.Registry = ""
+	// a dry run renders what would be released with the images named after the build
+	// context, without talking to docker or the cluster.
+	if u.dryRun {
+		return bldr.DryRun(buildctx, u.out)
 	}
 
-	if configuredResourceGroup, ok := globalConfig[resourceGroupName.name]; ok {
-		buildctx.Env.ResourceGroupName = configuredResourceGroup
+	// without a registry, images built for kind and minikube clusters are loaded straight onto their nodes.
+	var cluster *localcluster.Cluster
+	if buildctx.Env.Registry == "" {
+		if name, err := kubeContextName(kubeContext); err == nil {
+			cluster = localcluster.Detect(name)
+		}
 	}
 
-	if buildctx.Env.Registry == "" && !skipImagePush {
+	if buildctx.Env.Registry == "" && buildctx.Env.ImageArchive == "" && cluster == nil && !skipImagePush {
 		// give a way for minikube users (and users who understand what they're doing) a way to opt out
 		if _, ok := globalConfig[disablePushWarning.name]; !ok {
-			fmt.Fprintln(u.out, "WARNING: no registry has been set, therefore Draft will not push to a container registry. This can be fixed by running `draft config set registry docker.io/myusername`")
-			fmt.Fprintln(u.out, "Hint: this warning can be disabled by running `draft config set disable-push-warning 1`")
+			fmt.Fprintln(u.messages(), "WARNING: no registry has been set, therefore Draft will not push to a container registry. This can be fixed by running `draft config set registry docker.io/myusername`")
+			fmt.Fprintln(u.messages(), "Hint: this warning can be disabled by running `draft config set disable-push-warning 1`")
 		}
 	}
 
+	// setup kube
+	bldr.Kube, kubeConfig, err = getKubeClient(kubeContext)
+	if err != nil {
+		return fmt.Errorf("Could not get a kube client: %s", err)
+	}
+
 	var cb builder.ContainerBuilder
 	switch buildctx.Env.ContainerBuilder {
 	case "acrbuild":
@@ -219,6 +256,15 @@ func (u *upCmd) run(environment string) (err error) {
 			AdalToken:      token,
 			Subscription:   subscription,
 		}
+	case "buildkit":
+		cb = &buildkitcontainerbuilder.Builder{
+			Addr: buildkitcontainerbuilder.Host(),
+		}
+	case "incluster":
+		cb = &inclustercontainerbuilder.Builder{
+			Kube:       bldr.Kube,
+			RESTConfig: kubeConfig,
+		}
 	default:
 		// setup docker
 		cli := &command.DockerCli{}
@@ -229,35 +275,40 @@ func (u *upCmd) run(environment string) (err error) {
 			DockerClient: cli,
 		}
 	}
+	if _, ok := cb.(builder.ImageSaver); ok && cluster != nil {
+		cb = &localcluster.Builder{ContainerBuilder: cb, Cluster: cluster}
+	}
 	bldr.ContainerBuilder = cb
 
-	// setup kube
-	bldr.Kube, kubeConfig, err = getKubeClient(kubeContext)
-	if err != nil {
-		return fmt.Errorf("Could not get a kube client: %s", err)
-	}
-	bldr.Helm, err = setupHelm(bldr.Kube, kubeConfig, tillerNamespace)
-	if err != nil {
-		return fmt.Errorf("Could not get a helm client: %s", err)
+	// applications deployed from plain manifests or a kustomization are applied without a release backend.
+	if builder.DeploysObjects(buildctx.Env) {
+		bldr.Applier = apply.New(bldr.Kube)
+	} else if bldr.ReleaseBackend, err = newReleaseBackend(buildctx.Env); err != nil {
+		return err
 	}
 
 	// setup the storage engine
-	bldr.Storage = configmap.NewConfigMaps(bldr.Kube.CoreV1().ConfigMaps(tillerNamespace))
-	progressC := bldr.Up(ctx, buildctx)
-	opts := []cmdline.Option{cmdline.WithBuildID(bldr.ID)}
-
-	if quiet {
-		opts = append(opts, cmdline.WithStdout(ioutil.Discard))
+	if bldr.Storage, err = newStorage(u.storageEngine, u.home); err != nil {
+		return fmt.Errorf("Could not set up the storage engine: %v", err)
+	}
+	if bldr.Retention, err = retentionPolicy(buildctx.Env); err != nil {
+		return err
 	}
 
-	if displayEmoji {
-		opts = append(opts, cmdline.WithDisplayEmoji(displayEmoji))
+	if buildctx.Env.Watch || watch {
+		if err := u.watch(ctx, bldr, buildctx); ctx.Err() == nil {
+			return err
+		}
+		return errUpInterrupted
 	}
 
-	cmdline.Display(ctx, buildctx.Env.Name, progressC, opts...)
+	u.up(ctx, bldr, buildctx)
+	if ctx.Err() != nil {
+		return errUpInterrupted
+	}
 
 	if buildctx.Env.AutoConnect || autoConnect {
-		c := newConnectCmd(u.out)
+		c := newConnectCmd(u.messages())
 		return c.RunE(c, []string{})
 	}
 
@@ -272,6 +323,121 @@ func (u *upCmd) run(environment string) (err error) {
 	return nil
 }
 
+// configureEnv applies the global configuration and command line overrides to env.
+func (u *upCmd) configureEnv(env *manifest.Environment) {
+	if configuredBuilder, ok := globalConfig[containerBuilder.name]; ok {
+		env.ContainerBuilder = configuredBuilder
+	}
+
+	// if a registry has been set in their global config but nothing was in draft.toml, use that instead.
+	if reg, ok := globalConfig[registry.name]; ok {
+		env.Registry = reg
+	}
+
+	// Check if skip-image-push is specified. If so, unset registry.
+	if skipImagePush {
+		env.Registry = ""
+	}
+
+	if configuredResourceGroup, ok := globalConfig[resourceGroupName.name]; ok {
+		env.ResourceGroupName = configuredResourceGroup
+	}
+}
+
+// up performs a single build and release of buildctx, displaying its progress until
+// the build completes. Cancelling ctx aborts the build, whose stages in flight are
+// displayed as cancelled.
+func (u *upCmd) up(ctx context.Context, bldr *builder.Builder, buildctx *builder.Context) {
+	progressC := bldr.Up(ctx, buildctx)
+	opts := []cmdline.Option{cmdline.WithBuildID(bldr.ID)}
+	// the display follows the build until it has wound down rather than stopping with ctx.
+	displayCtx := context.Background()
+
+	if u.output == "json" {
+		cmdline.DisplayJSON(displayCtx, buildctx.Env.Name, progressC, append(opts, cmdline.WithStdout(u.out))...)
+	} else {
+		if quiet {
+			opts = append(opts, cmdline.WithStdout(ioutil.Discard))
+		}
+
+		if displayEmoji {
+			opts = append(opts, cmdline.WithDisplayEmoji(displayEmoji))
+		}
+
+		if verbose {
+			opts = append(opts, cmdline.Verbose())
+		}
+
+		cmdline.Display(displayCtx, buildctx.Env.Name, progressC, opts...)
+	}
+
+	if u.output == "json" {
+		// the build's stored record is the authoritative summary of what it produced.
+		obj, err := bldr.Storage.GetBuild(context.Background(), buildctx.Env.Name, bldr.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to retrieve build %s from storage: %v\n", bldr.ID, err)
+			return
+		}
+		cmdline.DisplayJSONResult(buildctx.Env.Name, obj, cmdline.WithStdout(u.out))
+	}
+}
+
+// messages returns where informational messages are written, keeping them out of
+// stdout when it carries machine-readable output.
+func (u *upCmd) messages() io.Writer {
+	if u.output == "json" {
+		return os.Stderr
+	}
+	return u.out
+}
+
+// watch builds and releases buildctx, then keeps rebuilding every time the application
+// directory changes. A build still in flight when a newer change arrives is cancelled.
+func (u *upCmd) watch(ctx context.Context, bldr *builder.Builder, buildctx *builder.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream := make(chan *builder.Context)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- buildctx.Watch(ctx, stream)
+	}()
+
+	for {
+		buildCtx, cancelBuild := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func(bldr *builder.Builder, buildctx *builder.Context) {
+			defer close(done)
+			u.up(buildCtx, bldr, buildctx)
+			if buildCtx.Err() == nil {
+				fmt.Fprintf(u.messages(), "Watching %s for changes...\n", buildctx.AppDir)
+			}
+		}(bldr, buildctx)
+
+		next, ok := <-stream
+		// a newer change supersedes the build in flight, if any.
+		inFlight := true
+		select {
+		case <-done:
+			inFlight = false
+		default:
+		}
+		cancelBuild()
+		<-done
+		// the archive of the superseded build context is no longer needed.
+		buildctx.Close()
+		if !ok {
+			return <-errc
+		}
+		// the image of a cancelled build was never deployed, so it must be built after all.
+		if inFlight && !buildctx.ChartOnly {
+			next.ChartOnly = false
+		}
+		u.configureEnv(next.Env)
+		bldr, buildctx = bldr.Clone(), next
+	}
+}
+
 func runPostDeployTasks(taskList *tasks.Tasks, buildID string) error {
 	if taskList == nil || len(taskList.PostDeploy) == 0 {
 		return errors.New("No post deploy tasks to run")
@@ -318,3 +484,21 @@ func getSubscriptionFromProfile() (azurecli.Subscription, error) {
 	}
 	return azurecli.Subscription{}, fmt.Errorf("could not find a default subscription ID from %s", profilePath)
 }
+
+// cancelOnInterrupt returns a copy of parent which is cancelled on the first interrupt or
+// termination signal, so that the build in flight is aborted rather than left running.
+// A second signal is handled by the runtime and terminates draft immediately.
+func cancelOnInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigc)
+		select {
+		case <-sigc:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
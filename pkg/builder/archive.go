@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Archive is the gzipped tarball of an application's build context.
+//
+// The archive is never held in memory: it is spooled to a temporary file, or read from the
+// `build-tar` configured in draft.toml, and container builders stream it from there. Its
+// checksum is computed while it is written.
+type Archive struct {
+	path   string
+	digest []byte
+	size   int64
+	// temp reports whether the archive is a temporary file to be removed by Close.
+	temp bool
+}
+
+// spoolArchive writes the archive read from r to a temporary file.
+func spoolArchive(r io.Reader) (*Archive, error) {
+	f, err := ioutil.TempFile("", "draft-context-")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &Archive{path: f.Name(), digest: h.Sum(nil), size: n, temp: true}, nil
+}
+
+// readArchive checksums the existing archive at path, which is read in place.
+func readArchive(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{path: path, digest: h.Sum(nil), size: n}, nil
+}
+
+// Open opens the archive for reading. The caller must close the returned file.
+func (a *Archive) Open() (*os.File, error) {
+	return os.Open(a.path)
+}
+
+// Digest returns the sha256 checksum of the archive.
+func (a *Archive) Digest() []byte {
+	return a.digest
+}
+
+// Size returns the size of the archive in bytes.
+func (a *Archive) Size() int64 {
+	return a.size
+}
+
+// Close removes the archive if it was spooled to a temporary file. It is safe to call
+// Close more than once.
+func (a *Archive) Close() error {
+	if !a.temp {
+		return nil
+	}
+	a.temp = false
+	return os.Remove(a.path)
+}
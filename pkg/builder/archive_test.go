@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpoolArchive(t *testing.T) {
+	content := []byte("build context")
+	ar, err := spoolArchive(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(content)
+	if !bytes.Equal(ar.Digest(), sum[:]) {
+		t.Errorf("expected digest %x, got %x", sum, ar.Digest())
+	}
+	if ar.Size() != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), ar.Size())
+	}
+
+	f, err := ar.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Errorf("expected archive content %q, got %q", content, b)
+	}
+
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(ar.path); !os.IsNotExist(err) {
+		t.Errorf("expected spooled archive to be removed, got %v", err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed, got %v", err)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "draft-archive-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "build.tar.gz")
+	content := []byte("build context")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := readArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	if !bytes.Equal(ar.Digest(), sum[:]) {
+		t.Errorf("expected digest %x, got %x", sum, ar.Digest())
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the build-tar not to be removed, got %v", err)
+	}
+}
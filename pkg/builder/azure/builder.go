@@ -1,7 +1,6 @@
 package azure
 
 import (
-	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -9,7 +8,6 @@ import (
 	"io"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
 	"github.com/Azure/draft/pkg/azure/blob"
@@ -40,179 +38,178 @@ func (b *Builder) Build(ctx context.Context, app *builder.AppContext, out chan<-
 	// notify that particular stage has started.
 	summary("started", builder.SummaryStarted)
 
-	msgc := make(chan string)
-	errc := make(chan error)
+	errc := make(chan error, 1)
 	go func() {
-		defer func() {
-			close(msgc)
-			close(errc)
-		}()
-		// the azure SDK wants only the name of the registry rather than the full registry URL
-		registryName := getRegistryName(app.Ctx.Env.Registry)
-		// first, upload the tarball to the upload storage URL given to us by acr build
-		sourceUploadDefinition, err := b.RegistryClient.GetBuildSourceUploadURL(ctx, app.Ctx.Env.ResourceGroupName, registryName)
-		if err != nil {
-			errc <- fmt.Errorf("Could not retrieve acr build's upload URL: %v", err)
-			return
-		}
-		u, err := url.Parse(*sourceUploadDefinition.UploadURL)
-		if err != nil {
-			errc <- fmt.Errorf("Could not parse blob upload URL: %v", err)
-			return
-		}
+		errc <- b.build(ctx, app, summary)
+	}()
+	return builder.Await(errc, summary)
+}
 
-		blockBlobService := azblob.NewBlockBlobURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
-		// Upload the application tarball to acr build
-		_, err = blockBlobService.PutBlob(ctx, bytes.NewReader(app.Ctx.Archive), azblob.BlobHTTPHeaders{ContentType: "application/gzip"}, azblob.Metadata{}, azblob.BlobAccessConditions{})
-		if err != nil {
-			errc <- fmt.Errorf("Could not upload docker context to acr build: %v", err)
-			return
-		}
+func (b *Builder) build(ctx context.Context, app *builder.AppContext, summary func(string, builder.SummaryStatusCode)) error {
+	// acr build always builds linux images for the default architecture.
+	if len(app.Ctx.Env.Platforms) > 0 {
+		return fmt.Errorf("container builder %q does not support building for platforms %s", app.Ctx.Env.ContainerBuilder, strings.Join(app.Ctx.Env.Platforms, ", "))
+	}
+	// the azure SDK wants only the name of the registry rather than the full registry URL
+	registryName := getRegistryName(app.Ctx.Env.Registry)
+	// first, upload the tarball to the upload storage URL given to us by acr build
+	sourceUploadDefinition, err := b.RegistryClient.GetBuildSourceUploadURL(ctx, app.Ctx.Env.ResourceGroupName, registryName)
+	if err != nil {
+		return fmt.Errorf("Could not retrieve acr build's upload URL: %v", err)
+	}
+	u, err := url.Parse(*sourceUploadDefinition.UploadURL)
+	if err != nil {
+		return fmt.Errorf("Could not parse blob upload URL: %v", err)
+	}
 
-		var imageNames []string
-		for i := range app.Images {
-			imageNameParts := strings.Split(app.Images[i], ":")
-			// get the tag name from the image name
-			imageNames = append(imageNames, fmt.Sprintf("%s:%s", app.Ctx.Env.Name, imageNameParts[len(imageNameParts)-1]))
-		}
+	src, err := app.Ctx.Archive.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-		var args []containerregistry.BuildArgument
+	blockBlobService := azblob.NewBlockBlobURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+	// Upload the application tarball to acr build
+	_, err = blockBlobService.PutBlob(ctx, src, azblob.BlobHTTPHeaders{ContentType: "application/gzip"}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+	if err != nil {
+		return fmt.Errorf("Could not upload docker context to acr build: %v", err)
+	}
 
-		// TODO: once the API includes this as default, remove it
-		buildArgType := "DockerBuildArgument"
-		for k := range app.Ctx.Env.ImageBuildArgs {
-			name := k
-			value := app.Ctx.Env.ImageBuildArgs[k]
-			arg := containerregistry.BuildArgument{
-				Type:  &buildArgType,
-				Name:  &name,
-				Value: &value,
-			}
-			args = append(args, arg)
-		}
+	var imageNames []string
+	for i := range app.Images {
+		imageNameParts := strings.Split(app.Images[i], ":")
+		// get the tag name from the image name
+		imageNames = append(imageNames, fmt.Sprintf("%s:%s", app.Ctx.Env.Name, imageNameParts[len(imageNameParts)-1]))
+	}
 
-		req := containerregistry.QuickBuildRequest{
-			ImageNames:     to.StringSlicePtr(imageNames),
-			SourceLocation: sourceUploadDefinition.RelativePath,
-			BuildArguments: &args,
-			IsPushEnabled:  to.BoolPtr(true),
-			Timeout:        to.Int32Ptr(600),
-			Platform: &containerregistry.PlatformProperties{
-				// TODO: make this configurable once ACR build supports windows containers
-				OsType: containerregistry.Linux,
-				// NB: CPU isn't required right now, possibly want to make this configurable
-				// It'll actually default to 2 from the server
-				// CPU: to.Int32Ptr(1),
-			},
-			// TODO: make this configurable
-			DockerFilePath: to.StringPtr("Dockerfile"),
-			Type:           containerregistry.TypeQuickBuild,
-		}
-		bas, ok := req.AsBasicQueueBuildRequest()
-		if !ok {
-			errc <- errors.New("Failed to create quick build request")
-			return
-		}
-		future, err := b.RegistryClient.QueueBuild(ctx, app.Ctx.Env.ResourceGroupName, registryName, bas)
-		if err != nil {
-			errc <- fmt.Errorf("Could not while queue acr build: %v", err)
-			return
-		}
+	var args []containerregistry.BuildArgument
 
-		if err := future.WaitForCompletion(ctx, b.RegistryClient.Client); err != nil {
-			errc <- fmt.Errorf("Could not wait for acr build to complete: %v", err)
-			return
+	// TODO: once the API includes this as default, remove it
+	buildArgType := "DockerBuildArgument"
+	for k := range app.Ctx.Env.ImageBuildArgs {
+		name := k
+		value := app.Ctx.Env.ImageBuildArgs[k]
+		arg := containerregistry.BuildArgument{
+			Type:  &buildArgType,
+			Name:  &name,
+			Value: &value,
 		}
+		args = append(args, arg)
+	}
 
-		fin, err := future.Result(b.RegistryClient)
-		if err != nil {
-			errc <- fmt.Errorf("Could not retrieve acr build future result: %v", err)
-			return
-		}
+	req := containerregistry.QuickBuildRequest{
+		ImageNames:     to.StringSlicePtr(imageNames),
+		SourceLocation: sourceUploadDefinition.RelativePath,
+		BuildArguments: &args,
+		IsPushEnabled:  to.BoolPtr(true),
+		Timeout:        to.Int32Ptr(600),
+		Platform: &containerregistry.PlatformProperties{
+			// TODO: make this configurable once ACR build supports windows containers
+			OsType: containerregistry.Linux,
+			// NB: CPU isn't required right now, possibly want to make this configurable
+			// It'll actually default to 2 from the server
+			// CPU: to.Int32Ptr(1),
+		},
+		// TODO: make this configurable
+		DockerFilePath: to.StringPtr("Dockerfile"),
+		Type:           containerregistry.TypeQuickBuild,
+	}
+	bas, ok := req.AsBasicQueueBuildRequest()
+	if !ok {
+		return errors.New("Failed to create quick build request")
+	}
+	future, err := b.RegistryClient.QueueBuild(ctx, app.Ctx.Env.ResourceGroupName, registryName, bas)
+	if err != nil {
+		return fmt.Errorf("Could not while queue acr build: %v", err)
+	}
 
-		logResult, err := b.BuildsClient.GetLogLink(ctx, app.Ctx.Env.ResourceGroupName, registryName, *fin.BuildID)
-		if err != nil {
-			errc <- fmt.Errorf("Could not retrieve acr build logs: %v", err)
-			return
-		}
+	if err := future.WaitForCompletion(ctx, b.RegistryClient.Client); err != nil {
+		return fmt.Errorf("Could not wait for acr build to complete: %v", err)
+	}
 
-		if *logResult.LogLink == "" {
-			errc <- errors.New("Unable to create a link to the logs: no link found")
-			return
-		}
+	fin, err := future.Result(b.RegistryClient)
+	if err != nil {
+		return fmt.Errorf("Could not retrieve acr build future result: %v", err)
+	}
 
-		blobURL := blob.GetAppendBlobURL(*logResult.LogLink)
+	logResult, err := b.BuildsClient.GetLogLink(ctx, app.Ctx.Env.ResourceGroupName, registryName, *fin.BuildID)
+	if err != nil {
+		return fmt.Errorf("Could not retrieve acr build logs: %v", err)
+	}
 
-		// Used for progress reporting to report the total number of bytes being downloaded.
-		var contentLength int64
-		rs := azblob.NewDownloadStream(ctx,
-			// We pass more than "blobUrl.GetBlob" here so we can capture the blob's full
-			// content length on the very first internal call to Read.
-			func(ctx context.Context, blobRange azblob.BlobRange, ac azblob.BlobAccessConditions, rangeGetContentMD5 bool) (*azblob.GetResponse, error) {
-				for {
-					properties, err := blobURL.GetPropertiesAndMetadata(ctx, ac)
-					if err != nil {
-						// retry if the blob doesn't exist yet
-						if strings.Contains(err.Error(), "The specified blob does not exist.") {
-							continue
-						}
-						return nil, err
-					}
-					// retry if the blob hasn't "completed"
-					if !blobComplete(properties.NewMetadata()) {
+	if *logResult.LogLink == "" {
+		return errors.New("Unable to create a link to the logs: no link found")
+	}
+
+	blobURL := blob.GetAppendBlobURL(*logResult.LogLink)
+
+	// Used for progress reporting to report the total number of bytes being downloaded.
+	var contentLength int64
+	rs := azblob.NewDownloadStream(ctx,
+		// We pass more than "blobUrl.GetBlob" here so we can capture the blob's full
+		// content length on the very first internal call to Read.
+		func(ctx context.Context, blobRange azblob.BlobRange, ac azblob.BlobAccessConditions, rangeGetContentMD5 bool) (*azblob.GetResponse, error) {
+			for {
+				properties, err := blobURL.GetPropertiesAndMetadata(ctx, ac)
+				if err != nil {
+					// retry if the blob doesn't exist yet
+					if strings.Contains(err.Error(), "The specified blob does not exist.") {
 						continue
 					}
-					break
-				}
-				resp, err := blobURL.GetBlob(ctx, blobRange, ac, rangeGetContentMD5)
-				if err != nil {
 					return nil, err
 				}
-				if contentLength == 0 {
-					// If 1st successful Get, record blob's full size for progress reporting
-					contentLength = resp.ContentLength()
+				// retry if the blob hasn't "completed"
+				if !blobComplete(properties.NewMetadata()) {
+					continue
 				}
-				return resp, nil
-			},
-			azblob.DownloadStreamOptions{})
-		defer rs.Close()
+				break
+			}
+			resp, err := blobURL.GetBlob(ctx, blobRange, ac, rangeGetContentMD5)
+			if err != nil {
+				return nil, err
+			}
+			if contentLength == 0 {
+				// If 1st successful Get, record blob's full size for progress reporting
+				contentLength = resp.ContentLength()
+			}
+			return resp, nil
+		},
+		azblob.DownloadStreamOptions{})
+	defer rs.Close()
 
-		_, err = io.Copy(app.Log, rs)
-		if err != nil {
-			errc <- fmt.Errorf("Could not stream acr build logs: %v", err)
-			return
-		}
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
+	if _, err = io.Copy(w, rs); err != nil {
+		return fmt.Errorf("Could not stream acr build logs: %v", err)
+	}
 
-		return
+	build, err := b.BuildsClient.Get(ctx, app.Ctx.Env.ResourceGroupName, registryName, *fin.BuildID)
+	if err != nil {
+		return fmt.Errorf("Could not retrieve acr build result: %v", err)
+	}
+	app.Digest = outputDigest(build, app.Ctx.Env.Name, imageNames[0])
+	return nil
+}
 
-	}()
-	for msgc != nil || errc != nil {
-		select {
-		case msg, ok := <-msgc:
-			if !ok {
-				msgc = nil
-				continue
-			}
-			summary(msg, builder.SummaryLogging)
-		case err, ok := <-errc:
-			if !ok {
-				errc = nil
-				continue
-			}
-			return err
-		default:
-			summary("ongoing", builder.SummaryOngoing)
-			time.Sleep(time.Second)
+// outputDigest returns the digest of the image pushed by an acr build, or "" if the
+// build did not report it.
+func outputDigest(build containerregistry.Build, repository, image string) string {
+	if build.BuildProperties == nil || build.OutputImages == nil {
+		return ""
+	}
+	tag := image[strings.LastIndex(image, ":")+1:]
+	for _, img := range *build.OutputImages {
+		if to.String(img.RepositoryName) == repository && to.String(img.Tag) == tag {
+			return to.String(img.Digest)
 		}
 	}
-	return nil
+	return ""
 }
 
 // Push pushes the results of Build to the image repository.
-func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) (err error) {
-	// no-op: acr build pushes to the registry through the quickbuild request
-	const stageDesc = "Building Docker Image"
-	builder.Complete(app.ID, stageDesc, out, &err)
+func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) error {
+	// no-op: acr build pushes to the registry through the quickbuild request, and Build
+	// completes the build stage, which would otherwise be recorded twice.
 	return nil
 }
 
@@ -1,6 +1,11 @@
 package azure
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/Azure/draft/pkg/azure/containerregistry"
+	"github.com/Azure/go-autorest/autorest/to"
+)
 
 func TestGetRegistryName(t *testing.T) {
 	var registryNameTests = []struct {
@@ -21,3 +26,18 @@ func TestGetRegistryName(t *testing.T) {
 		})
 	}
 }
+
+func TestOutputDigest(t *testing.T) {
+	images := []containerregistry.ImageDescriptor{
+		{RepositoryName: to.StringPtr("app"), Tag: to.StringPtr("latest"), Digest: to.StringPtr("sha256:1")},
+		{RepositoryName: to.StringPtr("app"), Tag: to.StringPtr("0123abcd"), Digest: to.StringPtr("sha256:2")},
+	}
+	build := containerregistry.Build{BuildProperties: &containerregistry.BuildProperties{OutputImages: &images}}
+
+	if digest := outputDigest(build, "app", "app:0123abcd"); digest != "sha256:2" {
+		t.Errorf("expected %v but got %v", "sha256:2", digest)
+	}
+	if digest := outputDigest(containerregistry.Build{}, "app", "app:0123abcd"); digest != "" {
+		t.Errorf("expected no digest but got %v", digest)
+	}
+}
@@ -1,7 +1,6 @@
 package builder
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -11,25 +10,26 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/Azure/draft/pkg/draft/ignore"
 	"github.com/Azure/draft/pkg/draft/manifest"
 	"github.com/Azure/draft/pkg/draft/pack"
 	"github.com/Azure/draft/pkg/local"
 	"github.com/Azure/draft/pkg/osutil"
 	"github.com/Azure/draft/pkg/storage"
 	"github.com/docker/cli/cli/command/image/build"
-	"github.com/docker/docker/builder/dockerignore"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/fileutils"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/helm/pkg/chartutil"
-	"k8s.io/helm/pkg/helm"
 	"k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/proto/hapi/release"
 	"k8s.io/helm/pkg/strvals"
@@ -48,10 +48,16 @@ const (
 type Builder struct {
 	ID               string
 	ContainerBuilder ContainerBuilder
-	Helm             helm.Interface
-	Kube             k8s.Interface
-	Storage          storage.Store
-	LogsDir          string
+	ReleaseBackend   ReleaseBackend
+	// Applier applies the objects of applications deployed from plain manifests or a
+	// kustomization instead of a chart.
+	Applier ObjectApplier
+	Kube    k8s.Interface
+	Storage storage.Store
+	LogsDir string
+	// Retention limits the build history kept for an application. It is enforced
+	// every time a build is stored.
+	Retention storage.RetentionPolicy
 }
 
 // ContainerBuilder defines how a container is built and pushed to a container registry using the supplied app context.
@@ -61,6 +67,22 @@ type ContainerBuilder interface {
 	AuthToken(ctx context.Context, app *AppContext) (string, error)
 }
 
+// ReleaseBackend defines how the chart of an application is released to the cluster, and
+// how the release history of the application is kept.
+type ReleaseBackend interface {
+	// Release returns the latest revision of the release of env, or ErrReleaseNotFound.
+	Release(env *manifest.Environment) (*release.Release, error)
+	// Install installs chart as the release of env, with vals overriding the chart's values.
+	Install(ctx context.Context, env *manifest.Environment, chart *chart.Chart, vals []byte) (*release.Release, error)
+	// Upgrade upgrades the release of env to chart. If reuseValues is set, vals are merged
+	// into the values of the latest revision instead of the chart's values.
+	Upgrade(ctx context.Context, env *manifest.Environment, chart *chart.Chart, vals []byte, reuseValues bool) (*release.Release, error)
+	// Rollback redeploys the given revision of the release of env as a new revision.
+	Rollback(ctx context.Context, env *manifest.Environment, version int32) (*release.Release, error)
+	// Delete deletes the release of env along with its history.
+	Delete(ctx context.Context, env *manifest.Environment) error
+}
+
 // Logs returns the path to the build logs.
 //
 // Set after Up is called (otherwise "").
@@ -75,8 +97,20 @@ type Context struct {
 	AppDir  string
 	Chart   *chart.Chart
 	Values  *chart.Config
+	// Objects are the objects deployed instead of Chart when the environment points at plain
+	// manifests or a kustomization.
+	Objects []*unstructured.Unstructured
 	SrcName string
-	Archive []byte
+	Archive *Archive
+	// Service is the name of the service built from this context, if it is the build context
+	// of one of the Services of a multi-service application.
+	Service string
+	// Services are the build contexts of the services of a multi-service application, which
+	// are built instead of the application directory.
+	Services []*Context
+	// ChartOnly deploys the chart with the image of the latest successful build instead
+	// of building a new image.
+	ChartOnly bool
 }
 
 // AppContext contains state information carried across the various draft stage boundaries.
@@ -84,12 +118,17 @@ type AppContext struct {
 	Obj       *storage.Object
 	Bldr      *Builder
 	Ctx       *Context
-	Buf       *bytes.Buffer
 	MainImage string
 	Images    []string
 	Log       io.WriteCloser
 	ID        string
 	Vals      chartutil.Values
+	// Digest is the digest of the image manifest, or of the manifest list for multi-platform
+	// images, set by the container builder once pushed.
+	Digest string
+	// Services are the app contexts of the services of a multi-service application. Each
+	// service is built and pushed on its own, sharing the build log of the application.
+	Services []*AppContext
 }
 
 // New creates a new Builder.
@@ -99,83 +138,104 @@ func New() *Builder {
 	}
 }
 
+// Clone returns a copy of the builder sharing its clients and storage engine,
+// but with a newly generated build ID.
+func (b *Builder) Clone() *Builder {
+	clone := *b
+	clone.ID = getulid()
+	return &clone
+}
+
 // newAppContext prepares state carried across the various draft stage boundaries.
 func newAppContext(b *Builder, buildCtx *Context) (*AppContext, error) {
-	raw := bytes.NewBuffer(buildCtx.Archive)
-	// write build context to a buffer so we can also write to the sha256 hash.
-	buf := new(bytes.Buffer)
-	h := sha256.New()
-	w := io.MultiWriter(buf, h)
-	if _, err := io.Copy(w, raw); err != nil {
-		return nil, err
-	}
-	// truncate checksum to the first 40 characters (20 bytes) this is the
-	// equivalent of `shasum build.tar.gz | awk '{print $1}'`.
-	ctxtID := h.Sum(nil)
-	imgtag := fmt.Sprintf("%.20x", ctxtID)
-	// if registry == "", then we just assume the image name is the app name and strip out the leading /
-	imageRepository := strings.TrimLeft(fmt.Sprintf("%s/%s", buildCtx.Env.Registry, buildCtx.Env.Name), "/")
-	image := fmt.Sprintf("%s:%s", imageRepository, imgtag)
-
-	images := []string{image}
-	for _, tag := range buildCtx.Env.CustomTags {
-		images = append(images, fmt.Sprintf("%s:%s", imageRepository, tag))
+	var (
+		ctxtID   []byte
+		image    string
+		images   []string
+		services []*AppContext
+		// inject certain values into the chart such as the registry location,
+		// the application name, buildID and the application version.
+		inject = []string{
+			fmt.Sprintf("%s=%s", local.DraftLabelKey, buildCtx.Env.Name),
+			fmt.Sprintf("%s=%s", local.BuildIDKey, b.ID),
+		}
+	)
+	if len(buildCtx.Services) == 0 {
+		// truncate checksum to the first 40 characters (20 bytes) this is the
+		// equivalent of `shasum build.tar.gz | awk '{print $1}'`.
+		ctxtID = buildCtx.Archive.Digest()
+		images = imageNames(buildCtx.Env, ctxtID)
+		image = images[0]
+		repository, tag := splitImage(image)
+		inject = append(inject, "image.repository="+repository, "image.tag="+tag)
+	} else {
+		// a multi-service application is identified by the build contexts of all of its
+		// services, and each service's image is injected under the name of the service.
+		h := sha256.New()
+		for _, svcCtx := range buildCtx.Services {
+			svc := newServiceContext(b, svcCtx)
+			h.Write(svc.Obj.ContextID)
+			images = append(images, svc.Images...)
+			services = append(services, svc)
+			repository, tag := splitImage(svc.MainImage)
+			inject = append(inject, svcCtx.Service+".image.repository="+repository, svcCtx.Service+".image.tag="+tag)
+		}
+		ctxtID = h.Sum(nil)
+		image = services[0].MainImage
 	}
 
-	// inject certain values into the chart such as the registry location,
-	// the application name, buildID and the application version.
-	tplstr := "image.repository=%s,image.tag=%s,%s=%s,%s=%s"
-	inject := fmt.Sprintf(tplstr, imageRepository, imgtag, local.DraftLabelKey, buildCtx.Env.Name, local.BuildIDKey, b.ID)
-
 	vals, err := chartutil.ReadValues([]byte(buildCtx.Values.Raw))
 	if err != nil {
 		return nil, err
 	}
-	if err := strvals.ParseInto(inject, vals); err != nil {
-		return nil, err
-	}
-
-	err = osutil.EnsureDirectory(filepath.Dir(b.Logs(buildCtx.Env.Name)))
-	if err != nil {
+	if err := strvals.ParseInto(strings.Join(inject, ","), vals); err != nil {
 		return nil, err
 	}
 
-	logf, err := os.OpenFile(b.Logs(buildCtx.Env.Name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return nil, err
-	}
+	revision, dirty := vcsRevision(buildCtx.AppDir)
 	state := &storage.Object{
 		BuildID:     b.ID,
 		ContextID:   ctxtID,
 		LogsFileRef: b.Logs(buildCtx.Env.Name),
+		Images:      images,
+		Environment: buildCtx.EnvName,
+		VcsRevision: revision,
+		VcsDirty:    dirty,
 	}
 	return &AppContext{
 		Obj:       state,
 		ID:        b.ID,
 		Bldr:      b,
 		Ctx:       buildCtx,
-		Buf:       buf,
 		Images:    images,
 		MainImage: image,
-		Log:       logf,
 		Vals:      vals,
+		Services:  services,
 	}, nil
 }
 
+// imageNames returns the names of the image built from a build context with the given
+// checksum: the image tagged with the checksum, followed by the custom tags of env.
+func imageNames(env *manifest.Environment, ctxtID []byte) []string {
+	imgtag := fmt.Sprintf("%.20x", ctxtID)
+	// if registry == "", then we just assume the image name is the app name and strip out the leading /
+	imageRepository := strings.TrimLeft(fmt.Sprintf("%s/%s", env.Registry, env.Name), "/")
+	images := []string{fmt.Sprintf("%s:%s", imageRepository, imgtag)}
+	for _, tag := range env.CustomTags {
+		images = append(images, fmt.Sprintf("%s:%s", imageRepository, tag))
+	}
+	return images
+}
+
 // LoadWithEnv takes the directory of the application and the environment the application
 //  will be pushed to and returns a Context object with a merge of environment and app
-//  information
+//  information. The Context must be closed once it is no longer needed.
 func LoadWithEnv(appdir, whichenv string) (*Context, error) {
 	ctx := &Context{AppDir: appdir, EnvName: whichenv}
 	// read draft.toml from appdir.
-	mfst, err := manifest.Load(filepath.Join(appdir, "draft.toml"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal draft.toml from %q: %v", appdir, err)
-	}
-	// if environment does not exist return error.
-	var ok bool
-	if ctx.Env, ok = mfst.Environments[whichenv]; !ok {
-		return nil, fmt.Errorf("no environment named %q in draft.toml", whichenv)
+	var err error
+	if ctx.Env, err = loadEnv(appdir, whichenv); err != nil {
+		return nil, err
 	}
 	// load the chart and the build archive; if a chart directory is present
 	// this will be given priority over the chart archive specified by the
@@ -183,15 +243,31 @@ func LoadWithEnv(appdir, whichenv string) (*Context, error) {
 	// is built from scratch. If no chart directory exists but a chart-tar and
 	// build-tar exist, then these will be used for values extraction.
 	if err := loadArchive(ctx); err != nil {
+		ctx.Close()
 		return nil, fmt.Errorf("failed to load chart: %v", err)
 	}
 	// load values from chart and merge with env.Values.
 	if err := loadValues(ctx); err != nil {
+		ctx.Close()
 		return nil, fmt.Errorf("failed to parse chart values: %v", err)
 	}
 	return ctx, nil
 }
 
+// loadEnv reads the environment named envName from the draft.toml in appDir.
+func loadEnv(appDir, envName string) (*manifest.Environment, error) {
+	mfst, err := manifest.Load(filepath.Join(appDir, "draft.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal draft.toml from %q: %v", appDir, err)
+	}
+	// if environment does not exist return error.
+	env, ok := mfst.Environments[envName]
+	if !ok {
+		return nil, fmt.Errorf("no environment named %q in draft.toml", envName)
+	}
+	return env, nil
+}
+
 // loadArchive loads the chart package and build archive.
 // Precedence is given to the `build-tar` and `chart-tar`
 // indicated in the `draft.toml` if present. Otherwise,
@@ -199,12 +275,10 @@ func LoadWithEnv(appdir, whichenv string) (*Context, error) {
 // app directory to send to the draft server.
 func loadArchive(ctx *Context) (err error) {
 	if ctx.Env.BuildTarPath != "" && ctx.Env.ChartTarPath != "" {
-		b, err := ioutil.ReadFile(ctx.Env.BuildTarPath)
-		if err != nil {
+		if ctx.Archive, err = readArchive(ctx.Env.BuildTarPath); err != nil {
 			return fmt.Errorf("failed to load build archive %q: %v", ctx.Env.BuildTarPath, err)
 		}
 		ctx.SrcName = filepath.Base(ctx.Env.BuildTarPath)
-		ctx.Archive = b
 
 		ar, err := os.Open(ctx.Env.ChartTarPath)
 		if err != nil {
@@ -215,7 +289,18 @@ func loadArchive(ctx *Context) (err error) {
 		}
 		return nil
 	}
-	if err = archiveSrc(ctx); err != nil {
+	if len(ctx.Env.Services) > 0 {
+		err = loadServices(ctx)
+	} else {
+		err = archiveSrc(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	// applications deployed from plain manifests or a kustomization have no chart.
+	if DeploysObjects(ctx.Env) {
+		ctx.Objects, err = loadObjects(ctx)
 		return err
 	}
 
@@ -280,22 +365,23 @@ func archiveSrc(ctx *Context) error {
 	if err != nil {
 		return fmt.Errorf("cannot canonicalize dockerfile path %s: %v", relDockerfile, err)
 	}
-	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
-	if err != nil && !os.IsNotExist(err) {
+	// the build context honours both .dockerignore and .draftignore.
+	rules, err := ignore.ParseDir(contextDir)
+	if err != nil {
 		return err
 	}
-	defer f.Close()
+	excludes := rules.Patterns()
 
-	var excludes []string
-	if err == nil {
-		excludes, err = dockerignore.ReadAll(f)
-		if err != nil {
-			return err
+	// do not include the chart directory. That will be packaged separately.
+	if pattern, ok := contextPattern(contextDir, deployDir(ctx.Env)); ok {
+		excludes = append(excludes, pattern)
+	}
+	// nor the image archive written by previous builds.
+	if path := ctx.ImageArchivePath(); path != "" {
+		if pattern, ok := contextPattern(contextDir, path); ok {
+			excludes = append(excludes, pattern)
 		}
 	}
-
-	// do not include the chart directory. That will be packaged separately.
-	excludes = append(excludes, filepath.Join(contextDir, "chart"))
 	if err := build.ValidateContextDirectory(contextDir, excludes); err != nil {
 		return fmt.Errorf("error checking docker context: '%s'", err)
 	}
@@ -326,62 +412,189 @@ func archiveSrc(ctx *Context) error {
 	}
 	defer rc.Close()
 
-	var b bytes.Buffer
-	if _, err := io.Copy(&b, rc); err != nil {
-		return err
+	if ctx.Archive, err = spoolArchive(rc); err != nil {
+		return fmt.Errorf("could not write build context archive: %v", err)
 	}
 	ctx.SrcName = "build.tar.gz"
-	ctx.Archive = b.Bytes()
 	return nil
 }
 
+// contextPattern returns the exclude pattern matching path in the build context rooted at
+// contextDir, or false if path lies outside of the build context. Relative paths are
+// resolved against contextDir.
+func contextPattern(contextDir, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(contextDir, path)
+	}
+	rel, err := filepath.Rel(contextDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Close removes the build context archives which were spooled to temporary files.
+func (ctx *Context) Close() error {
+	var err error
+	for _, svc := range ctx.Services {
+		if cerr := svc.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if ctx.Archive != nil {
+		if cerr := ctx.Archive.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 // Up handles incoming draft up requests and returns a stream of summaries or error.
 func (b *Builder) Up(ctx context.Context, bctx *Context) <-chan *Summary {
 	ch := make(chan *Summary, 1)
-	var wg sync.WaitGroup
-	wg.Add(1)
 	go func() {
 		var (
 			app *AppContext
 			err error
 		)
+		// every summary passes through the stage recorder before it reaches the caller
+		// so that the outcome and duration of each stage is kept in the build history.
+		summaries := make(chan *Summary, 1)
+		stagesc := make(chan []*storage.Stage, 1)
+		go func() {
+			stagesc <- recordStages(summaries, ch)
+		}()
 		defer func() {
-			b.saveState(app)
-			wg.Done()
+			close(summaries)
+			b.saveState(bctx, app, <-stagesc, err)
+			close(ch)
 		}()
-		if app, err = newAppContext(b, bctx); err != nil {
+		if app, err = b.prepare(bctx, summaries); err != nil {
 			log.Printf("error creating app context: %v\n", err)
 			return
 		}
 		log.SetOutput(app.Log)
-		if err := b.ContainerBuilder.Build(ctx, app, ch); err != nil {
-			log.Printf("error while building: %v\n", err)
-			return
-		}
-		if err := b.ContainerBuilder.Push(ctx, app, ch); err != nil {
-			log.Printf("error while pushing: %v\n", err)
-			return
-		}
-		if err := b.release(ctx, app, ch); err != nil {
-			log.Printf("error while releasing: %v\n", err)
-			return
-		}
-	}()
-	go func() {
-		wg.Wait()
-		close(ch)
+		stages, done := b.stages(app, summaries)
+		err = runStages(ctx, stages)
+		done()
 	}()
 	return ch
 }
 
+// recordStages forwards summaries from in to out until in is closed, returning the
+// stages which completed along with how long each of them took.
+//
+// A stage is timed from the first summary seen for its description.
+func recordStages(in <-chan *Summary, out chan<- *Summary) (stages []*storage.Stage) {
+	started := make(map[string]time.Time)
+	for summary := range in {
+		start, ok := started[summary.StageDesc]
+		if !ok {
+			start = time.Now()
+			started[summary.StageDesc] = start
+		}
+		if completed(summary.StatusCode) {
+			stages = append(stages, &storage.Stage{
+				Name:     summary.StageDesc,
+				Status:   statusName(summary.StatusCode),
+				Duration: ptypes.DurationProto(time.Since(start)),
+			})
+		}
+		out <- summary
+	}
+	return stages
+}
+
+// statusName returns the lower case name of code as recorded in the build history.
+func statusName(code SummaryStatusCode) string {
+	return strings.ToLower(SummaryStatusCodeName[int(code)])
+}
+
+// prepare creates the app context for a build, reporting a failed stage on out if
+// it could not be created so that the failure is visible and recorded.
+func (b *Builder) prepare(bctx *Context, out chan<- *Summary) (*AppContext, error) {
+	const stageDesc = "Preparing Build Context"
+
+	app, err := newAppContext(b, bctx)
+	if err == nil {
+		err = openLog(app)
+	}
+	if err != nil {
+		Summarize(b.ID, stageDesc, out)("started", SummaryStarted)
+		Complete(b.ID, stageDesc, out, &err)
+	}
+	return app, err
+}
+
+// openLog creates the build log of app, shared by the services of the application.
+func openLog(app *AppContext) error {
+	path := app.Bldr.Logs(app.Ctx.Env.Name)
+	if err := osutil.EnsureDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+	logf, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	app.Log = logf
+	for _, svc := range app.Services {
+		svc.Log = logf
+	}
+	return nil
+}
+
 // saveState saves information collected from a draft build.
-func (b *Builder) saveState(app *AppContext) {
-	if err := b.Storage.UpdateBuild(context.Background(), app.Ctx.Env.Name, app.Obj); err != nil {
-		log.Printf("complete: failed to store build object for app %q: %v\n", app.Ctx.Env.Name, err)
+//
+// A build object is stored for every attempted build, including builds which failed
+// before their app context could be created.
+func (b *Builder) saveState(bctx *Context, app *AppContext, stages []*storage.Stage, err error) {
+	if bctx == nil || bctx.Env == nil {
+		log.Printf("complete: no application to store build %q for\n", b.ID)
 		return
 	}
-	if app.Log != nil {
-		app.Log.Close()
+	var obj *storage.Object
+	if app != nil {
+		obj = app.Obj
+		if app.Log != nil {
+			defer app.Log.Close()
+		}
+	} else {
+		revision, dirty := vcsRevision(bctx.AppDir)
+		obj = &storage.Object{
+			BuildID:     b.ID,
+			Environment: bctx.EnvName,
+			VcsRevision: revision,
+			VcsDirty:    dirty,
+		}
+	}
+	recordOutcome(obj, stages, err)
+	if err := b.Storage.UpdateBuild(context.Background(), bctx.Env.Name, obj); err != nil {
+		log.Printf("complete: failed to store build object for app %q: %v\n", bctx.Env.Name, err)
+	}
+	// prune even when the build could not be stored, so that a failing store does not let
+	// the history of previous builds grow past the retention policy.
+	if _, err := b.prune(context.Background(), bctx.Env.Name, obj); err != nil {
+		log.Printf("complete: failed to prune build history for app %q: %v\n", bctx.Env.Name, err)
+	}
+}
+
+// recordOutcome records the stages of a build and whether it succeeded, failed or was
+// cancelled on obj.
+func recordOutcome(obj *storage.Object, stages []*storage.Stage, err error) {
+	obj.Stages = stages
+	if err != nil {
+		obj.Status = statusName(SummaryFailure)
+		if cancelled(err) {
+			obj.Status = statusName(SummaryCancelled)
+		}
+		obj.ErrorMessage = err.Error()
+		for _, stage := range stages {
+			if stage.Status == statusName(SummaryFailure) {
+				obj.FailedStage = stage.Name
+			}
+		}
+	} else {
+		obj.Status = statusName(SummarySuccess)
 	}
 }
 
@@ -402,69 +615,79 @@ func (b *Builder) release(ctx context.Context, app *AppContext, out chan<- *Summ
 		}
 	}
 
+	// tags can be overwritten, so pin the release to the digest of the pushed images.
+	if app.Digest != "" {
+		if err := pinDigest(app.Vals, "image", app.MainImage, app.Digest); err != nil {
+			return err
+		}
+	}
+	for _, svc := range app.Services {
+		if svc.Digest == "" {
+			continue
+		}
+		if err := pinDigest(app.Vals, svc.Ctx.Service+".image", svc.MainImage, svc.Digest); err != nil {
+			return err
+		}
+	}
+
+	if app.Ctx.Objects != nil {
+		return b.applyObjects(app, summary)
+	}
+
+	vals, err := app.Vals.YAML()
+	if err != nil {
+		return err
+	}
+
 	// If a release does not exist, install it. If another error occurs during the check,
 	// ignore the error and continue with the upgrade.
-	//
-	// The returned error is a gSummaryhat wraps the message from the original error.
-	// So we're stuck doing string matching against the wrapped error, which is nested inside
-	// of the gSummaryessage.
-	_, err = b.Helm.ReleaseContent(app.Ctx.Env.Name, helm.ContentReleaseVersion(1))
-	if err != nil && strings.Contains(err.Error(), "not found") {
+	var rls *release.Release
+	if _, err := b.ReleaseBackend.Release(app.Ctx.Env); err == ErrReleaseNotFound {
 		msg := fmt.Sprintf("Release %q does not exist. Installing it now.", app.Ctx.Env.Name)
 		summary(msg, SummaryLogging)
 
-		vals, err := app.Vals.YAML()
-		if err != nil {
-			return err
-		}
-
-		opts := []helm.InstallOption{
-			helm.ReleaseName(app.Ctx.Env.Name),
-			helm.ValueOverrides([]byte(vals)),
-			helm.InstallWait(app.Ctx.Env.Wait),
-		}
-		rls, err := b.Helm.InstallReleaseFromChart(app.Ctx.Chart, app.Ctx.Env.Namespace, opts...)
+		rls, err = b.ReleaseBackend.Install(ctx, app.Ctx.Env, app.Ctx.Chart, []byte(vals))
 		if err != nil {
 			return fmt.Errorf("could not install release: %v", err)
 		}
-		app.Obj.Release = rls.Release.Name
-		formatReleaseStatus(app, rls.Release, summary)
-
 	} else {
 		msg := fmt.Sprintf("Upgrading %s.", app.Ctx.Env.Name)
 		summary(msg, SummaryLogging)
 
-		vals, err := app.Vals.YAML()
-		if err != nil {
-			return err
-		}
-
-		opts := []helm.UpdateOption{
-			helm.UpdateValueOverrides([]byte(vals)),
-			helm.UpgradeWait(app.Ctx.Env.Wait),
-		}
-		rls, err := b.Helm.UpdateReleaseFromChart(app.Ctx.Env.Name, app.Ctx.Chart, opts...)
+		rls, err = b.ReleaseBackend.Upgrade(ctx, app.Ctx.Env, app.Ctx.Chart, []byte(vals), false)
 		if err != nil {
 			return fmt.Errorf("could not upgrade release: %v", err)
 		}
-		app.Obj.Release = rls.Release.Name
-		formatReleaseStatus(app, rls.Release, summary)
 	}
+	app.Obj.Release = rls.Name
+	app.Obj.ReleaseVersion = rls.Version
+	formatReleaseStatus(app, rls, summary)
+	return nil
+}
+
+// applyObjects applies the objects of an application deployed from plain manifests or a
+// kustomization, then deletes the objects of previous builds which are no longer part of it.
+func (b *Builder) applyObjects(app *AppContext, summary func(string, SummaryStatusCode)) error {
+	objs, err := prepareObjects(app.Ctx.Objects, imageReferences(app), objectLabels(app))
+	if err != nil {
+		return err
+	}
+	summary(fmt.Sprintf("applying %d objects to namespace %s", len(objs), app.Ctx.Env.Namespace), SummaryLogging)
+	if err := b.Applier.Apply(app.Ctx.Env.Namespace, objs); err != nil {
+		return fmt.Errorf("could not apply objects: %v", err)
+	}
+	selector := fmt.Sprintf("%s=%s,%s!=%s", local.DraftLabelKey, app.Ctx.Env.Name, local.BuildIDKey, app.ID)
+	if err := b.Applier.Delete(app.Ctx.Env.Namespace, selector); err != nil {
+		return fmt.Errorf("could not delete the objects of previous builds: %v", err)
+	}
+	app.Obj.Release = app.Ctx.Env.Name
 	return nil
 }
 
 func (b *Builder) prepareReleaseEnvironment(ctx context.Context, app *AppContext) error {
 	// determine if the destination namespace exists, create it if not.
-	if _, err := b.Kube.CoreV1().Namespaces().Get(app.Ctx.Env.Namespace, metav1.GetOptions{}); err != nil {
-		if !apiErrors.IsNotFound(err) {
-			return err
-		}
-		_, err = b.Kube.CoreV1().Namespaces().Create(&v1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{Name: app.Ctx.Env.Namespace},
-		})
-		if err != nil {
-			return fmt.Errorf("could not create namespace %q: %v", app.Ctx.Env.Namespace, err)
-		}
+	if err := EnsureNamespace(b.Kube, app.Ctx.Env.Namespace); err != nil {
+		return err
 	}
 
 	authToken, err := b.ContainerBuilder.AuthToken(ctx, app)
@@ -542,6 +765,32 @@ func (b *Builder) prepareReleaseEnvironment(ctx context.Context, app *AppContext
 	return nil
 }
 
+// EnsureNamespace creates the namespace if it does not exist yet.
+func EnsureNamespace(kube k8s.Interface, namespace string) error {
+	if _, err := kube.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{}); err != nil {
+		if !apiErrors.IsNotFound(err) {
+			return err
+		}
+		_, err = kube.CoreV1().Namespaces().Create(&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		})
+		if err != nil && !apiErrors.IsAlreadyExists(err) {
+			return fmt.Errorf("could not create namespace %q: %v", namespace, err)
+		}
+	}
+	return nil
+}
+
+// pinDigest injects the digest of image into the chart values under key, both as
+// <key>.digest and as the repository@digest reference <key>.reference. The digest is also
+// appended to <key>.tag so that charts referencing the image by repository and tag are
+// pinned too.
+func pinDigest(vals chartutil.Values, key, image, digest string) error {
+	repository, tag := splitImage(image)
+	inject := fmt.Sprintf("%[1]s.digest=%[2]s,%[1]s.reference=%[3]s@%[2]s,%[1]s.tag=%[4]s@%[2]s", key, digest, repository, tag)
+	return strvals.ParseInto(inject, vals)
+}
+
 func formatReleaseStatus(app *AppContext, rls *release.Release, summary func(string, SummaryStatusCode)) {
 	status := fmt.Sprintf("%s %v", app.Ctx.Env.Name, rls.Info.Status.Code)
 	summary(status, SummaryLogging)
@@ -558,9 +807,12 @@ func Summarize(id, desc string, out chan<- *Summary) func(string, SummaryStatusC
 	}
 }
 
-// Complete marks the end of a draft build stage.
+// Complete marks the end of a draft build stage. A stage which returned the error of a
+// cancelled context is reported as cancelled rather than failed.
 func Complete(id, desc string, out chan<- *Summary, err *error) {
 	switch fn := Summarize(id, desc, out); {
+	case *err != nil && cancelled(*err):
+		fn("cancelled", SummaryCancelled)
 	case *err != nil:
 		fn(fmt.Sprintf("failure: %v", *err), SummaryFailure)
 	default:
@@ -1,10 +1,20 @@
 package builder
 
 import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/Azure/draft/pkg/storage/inprocess"
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/chartutil"
 )
 
 func TestArchiveSrc(t *testing.T) {
@@ -16,13 +26,204 @@ func TestArchiveSrc(t *testing.T) {
 	}
 
 	if err := archiveSrc(ctx); err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	defer ctx.Close()
 
 	if ctx.SrcName != "build.tar.gz" {
 		t.Errorf("expected %s, got %s", "build.tar.gz", ctx.AppDir)
 	}
-	if len(ctx.Archive) == 0 {
-		t.Errorf("expected non-zero archive length, got %d", len(ctx.Archive))
+	if ctx.Archive.Size() == 0 {
+		t.Errorf("expected non-zero archive length, got %d", ctx.Archive.Size())
+	}
+}
+
+func TestContextPattern(t *testing.T) {
+	contextDir := filepath.FromSlash("/src/app")
+	var patternTests = []struct {
+		path    string
+		pattern string
+		ok      bool
+	}{
+		{"charts", "charts", true},
+		{filepath.FromSlash("deploy/chart/"), filepath.FromSlash("deploy/chart"), true},
+		{filepath.FromSlash("/src/app/image.tar"), "image.tar", true},
+		{filepath.FromSlash("/tmp/image.tar"), "", false},
+		{filepath.FromSlash("../other"), "", false},
+		{".", "", false},
+	}
+
+	for _, tt := range patternTests {
+		t.Run(tt.path, func(t *testing.T) {
+			pattern, ok := contextPattern(contextDir, tt.path)
+			if pattern != tt.pattern || ok != tt.ok {
+				t.Errorf("expected (%q, %v) but got (%q, %v)", tt.pattern, tt.ok, pattern, ok)
+			}
+		})
+	}
+}
+
+func TestRecordStages(t *testing.T) {
+	in := make(chan *Summary, 5)
+	out := make(chan *Summary, 5)
+	summary := Summarize("id", "Building Docker Image", in)
+	summary("started", SummaryStarted)
+	summary("step 1/2", SummaryLogging)
+	summary("success", SummarySuccess)
+	Summarize("id", "Pushing Docker Image", in)("failure: denied", SummaryFailure)
+	close(in)
+
+	stages := recordStages(in, out)
+	if len(out) != 4 {
+		t.Errorf("expected 4 forwarded summaries, got %d", len(out))
+	}
+	if len(stages) != 2 {
+		t.Fatalf("expected 2 recorded stages, got %d", len(stages))
+	}
+	expected := []struct{ name, status string }{
+		{"Building Docker Image", "success"},
+		{"Pushing Docker Image", "failure"},
+	}
+	for i, stage := range stages {
+		if stage.Name != expected[i].name || stage.Status != expected[i].status {
+			t.Errorf("expected stage %d to be %s (%s), got %s (%s)", i, expected[i].name, expected[i].status, stage.Name, stage.Status)
+		}
+		if stage.Duration == nil {
+			t.Errorf("expected stage %d to record its duration", i)
+		}
+	}
+}
+
+func TestSaveStateWithoutAppContext(t *testing.T) {
+	b := &Builder{ID: "foo", Storage: inprocess.NewStore()}
+	bctx := &Context{
+		AppDir:  filepath.Join("testdata", "simple"),
+		EnvName: "development",
+		Env:     &manifest.Environment{Name: "app"},
+	}
+	stages := []*storage.Stage{{Name: "Preparing Build Context", Status: "failure"}}
+	b.saveState(bctx, nil, stages, errors.New("no chart"))
+
+	obj, err := b.Storage.GetBuild(context.Background(), "app", "foo")
+	if err != nil {
+		t.Fatalf("failed to get build: %v", err)
+	}
+	if obj.Status != "failure" || obj.ErrorMessage != "no chart" || obj.FailedStage != "Preparing Build Context" {
+		t.Errorf("expected failure in %q with %q, got %s in %q with %q", "Preparing Build Context", "no chart", obj.Status, obj.FailedStage, obj.ErrorMessage)
+	}
+	if obj.Environment != "development" {
+		t.Errorf("expected environment %q, got %q", "development", obj.Environment)
+	}
+}
+
+type fakeSaver struct{ ContainerBuilder }
+
+func (fakeSaver) SaveImage(ctx context.Context, app *AppContext, w io.Writer, format string, summary func(string, SummaryStatusCode)) (string, error) {
+	_, err := io.WriteString(w, format+" archive")
+	return "sha256:abc", err
+}
+
+func TestSaveImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "draft-save-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app := &AppContext{
+		ID:  "foo",
+		Obj: &storage.Object{},
+		Ctx: &Context{
+			AppDir: dir,
+			Env:    &manifest.Environment{ImageArchive: filepath.Join("out", "image.tar"), ImageArchiveFormat: ImageArchiveOCI},
+		},
+	}
+	out := make(chan *Summary, 10)
+
+	b := &Builder{ContainerBuilder: fakeSaver{}}
+	if err := b.saveImage(context.Background(), app, out); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	path := filepath.Join(dir, "out", "image.tar")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected image archive to be written: %v", err)
+	}
+	if string(data) != "oci archive" {
+		t.Errorf("expected %q, got %q", "oci archive", data)
+	}
+	// the digest of the archive is recorded, not the one of the image in it.
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	if app.Obj.ImageArchiveRef != path || app.Obj.ImageArchiveDigest != digest {
+		t.Errorf("expected %s (%s) to be recorded, got %s (%s)", path, digest, app.Obj.ImageArchiveRef, app.Obj.ImageArchiveDigest)
+	}
+
+	// container builders which cannot save images fail the stage.
+	b.ContainerBuilder = nil
+	if err := b.saveImage(context.Background(), app, out); err == nil {
+		t.Error("expected an error from a container builder which cannot save images")
+	}
+}
+
+func TestPinDigest(t *testing.T) {
+	const digest = "sha256:0a4e5d1b4f9a7b73a2b7b2a8e34a87e7d5bd1cc70ab0bdb8e18b3ea5e5d2b4ac"
+	vals := chartutil.Values{}
+	if err := pinDigest(vals, "image", "myregistry.io:5000/app:0123abcd", digest); err != nil {
+		t.Fatalf("failed to pin digest: %v", err)
+	}
+	image, err := vals.Table("image")
+	if err != nil {
+		t.Fatalf("expected image values to be set: %v", err)
+	}
+	expected := map[string]string{
+		"digest":    digest,
+		"reference": "myregistry.io:5000/app@" + digest,
+		"tag":       "0123abcd@" + digest,
+	}
+	for key, value := range expected {
+		if image[key] != value {
+			t.Errorf("expected image.%s to be %q, got %q", key, value, image[key])
+		}
+	}
+}
+
+type fakeFinder struct {
+	ContainerBuilder
+	exists bool
+}
+
+func (f fakeFinder) FindImage(ctx context.Context, app *AppContext) (bool, error) {
+	return f.exists, nil
+}
+
+func TestImageExists(t *testing.T) {
+	app := &AppContext{
+		ID:        "foo",
+		MainImage: "app:0123abcd",
+		Ctx:       &Context{Env: &manifest.Environment{}},
+	}
+	out := make(chan *Summary, 2)
+
+	b := &Builder{ContainerBuilder: fakeFinder{exists: false}}
+	if b.imageExists(context.Background(), app, out) {
+		t.Error("expected the image to be built when it does not exist")
+	}
+	if len(out) != 0 {
+		t.Errorf("expected no summaries, got %d", len(out))
+	}
+
+	b.ContainerBuilder = fakeFinder{exists: true}
+	if !b.imageExists(context.Background(), app, out) {
+		t.Error("expected the build to be skipped when the image exists")
+	}
+	<-out
+	if summary := <-out; summary.StatusCode != SummaryCached {
+		t.Errorf("expected the build stage to be reported as %s, got %s", SummaryStatusCodeName[int(SummaryCached)], SummaryStatusCodeName[int(summary.StatusCode)])
+	}
+
+	// images written to an image archive are always built.
+	app.Ctx.Env.ImageArchive = "image.tar"
+	if b.imageExists(context.Background(), app, out) {
+		t.Error("expected the image to be built when it is written to an image archive")
 	}
 }
@@ -0,0 +1,271 @@
+package buildkit
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"golang.org/x/net/context"
+)
+
+const (
+	// HostEnvVar is the environment variable holding the address of buildkitd.
+	HostEnvVar = "BUILDKIT_HOST"
+	// DefaultHost is the address of buildkitd if $BUILDKIT_HOST is not set.
+	DefaultHost = "unix:///run/buildkit/buildkitd.sock"
+
+	// imageDigestKey is the exporter response entry holding the digest of the exported image.
+	imageDigestKey = "containerimage.digest"
+)
+
+// Builder builds container images with BuildKit.
+type Builder struct {
+	// Addr is the address of the buildkitd daemon.
+	Addr string
+}
+
+// Host returns the address of buildkitd from $BUILDKIT_HOST, or DefaultHost.
+func Host() string {
+	if host := os.Getenv(HostEnvVar); host != "" {
+		return host
+	}
+	return DefaultHost
+}
+
+// Build builds the docker image.
+func (b *Builder) Build(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) (err error) {
+	const stageDesc = "Building Docker Image"
+
+	defer builder.Complete(app.ID, stageDesc, out, &err)
+	summary := builder.Summarize(app.ID, stageDesc, out)
+
+	// notify that particular stage has started.
+	summary("started", builder.SummaryStarted)
+
+	cacheFrom, err := parseCacheOptions(app.Ctx.Env.CacheFrom, app.Ctx.AppDir, false)
+	if err != nil {
+		return err
+	}
+	cacheTo, err := parseCacheOptions(app.Ctx.Env.CacheTo, app.Ctx.AppDir, true)
+	if err != nil {
+		return err
+	}
+
+	dir, err := unpack(app)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	opt := b.solveOpt(app, dir, false)
+	opt.CacheImports = cacheFrom
+	opt.CacheExports = cacheTo
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := b.solve(ctx, app, opt, summary)
+		errc <- err
+	}()
+	return builder.Await(errc, summary)
+}
+
+// Push pushes the results of Build to the image repository.
+//
+// The image is solved again with the push option enabled; since Build has just
+// produced it, every step is a cache hit.
+func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) (err error) {
+	if app.Ctx.Env.Registry == "" {
+		return
+	}
+
+	const stageDesc = "Pushing Docker Image"
+
+	defer builder.Complete(app.ID, stageDesc, out, &err)
+	summary := builder.Summarize(app.ID, stageDesc, out)
+
+	// notify that particular stage has started.
+	summary("started", builder.SummaryStarted)
+
+	dir, err := unpack(app)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var resp *client.SolveResponse
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		resp, err = b.solve(ctx, app, b.solveOpt(app, dir, true), summary)
+		errc <- err
+	}()
+	if err := builder.Await(errc, summary); err != nil {
+		return err
+	}
+	// for multi-platform images this is the digest of the manifest list.
+	app.Digest = resp.ExporterResponse[imageDigestKey]
+	return nil
+}
+
+// AuthToken retrieves the auth token for the given image from the docker CLI configuration.
+func (b *Builder) AuthToken(ctx context.Context, app *builder.AppContext) (string, error) {
+	return builder.AuthTokenFromDockerConfig(app.MainImage)
+}
+
+// unpack extracts the application's build context into a temporary directory.
+//
+// buildkit syncs the build context from a directory, so the archived context (which
+// already honours .dockerignore) is unpacked rather than the application directory
+// being used as is.
+func unpack(app *builder.AppContext) (string, error) {
+	dir, err := ioutil.TempDir("", "draft-buildkit")
+	if err != nil {
+		return "", err
+	}
+	src, err := app.Ctx.Archive.Open()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	defer src.Close()
+	if err := archive.Untar(src, dir, &archive.TarOptions{NoLchown: true}); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("could not unpack build context: %v", err)
+	}
+	return dir, nil
+}
+
+// SaveImage writes the images built for app to w as an OCI image layout or `docker save`
+// tarball, solving the image again with the matching exporter.
+func (b *Builder) SaveImage(ctx context.Context, app *builder.AppContext, w io.Writer, format string, summary func(string, builder.SummaryStatusCode)) (string, error) {
+	dir, err := unpack(app)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	var exporter string
+	switch format {
+	case builder.ImageArchiveOCI:
+		exporter = client.ExporterOCI
+	case builder.ImageArchiveDocker:
+		exporter = client.ExporterDocker
+	default:
+		return "", fmt.Errorf("the buildkit container builder cannot write %s image archives", format)
+	}
+	opt := b.solveOpt(app, dir, false)
+	opt.Exports = []client.ExportEntry{{
+		Type:  exporter,
+		Attrs: map[string]string{"name": strings.Join(app.Images, ",")},
+		// the caller owns w, so buildkit must not close it.
+		Output: nopCloser{w},
+	}}
+
+	resp, err := b.solve(ctx, app, opt, summary)
+	if err != nil {
+		return "", err
+	}
+	return resp.ExporterResponse[imageDigestKey], nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// solveOpt returns the options to build the application's Dockerfile from the build
+// context in dir with the dockerfile frontend.
+func (b *Builder) solveOpt(app *builder.AppContext, dir string, push bool) client.SolveOpt {
+	dockerfile := filepath.Join(dir, app.Ctx.Env.Dockerfile)
+	attrs := map[string]string{
+		"filename": filepath.Base(dockerfile),
+	}
+	for k, v := range app.Ctx.Env.ImageBuildArgs {
+		attrs["build-arg:"+k] = v
+	}
+	// building for several platforms produces a manifest list covering each of them.
+	if len(app.Ctx.Env.Platforms) > 0 {
+		attrs["platform"] = strings.Join(app.Ctx.Env.Platforms, ",")
+	}
+	return client.SolveOpt{
+		Exports: []client.ExportEntry{{
+			Type: client.ExporterImage,
+			Attrs: map[string]string{
+				"name": strings.Join(app.Images, ","),
+				"push": strconv.FormatBool(push),
+			},
+		}},
+		LocalDirs: map[string]string{
+			"context":    dir,
+			"dockerfile": filepath.Dir(dockerfile),
+		},
+		Frontend:      "dockerfile.v0",
+		FrontendAttrs: attrs,
+		Session:       []session.Attachable{authprovider.NewDockerAuthProvider()},
+	}
+}
+
+// solve runs opt against buildkitd, writing the build output to the build log.
+func (b *Builder) solve(ctx context.Context, app *builder.AppContext, opt client.SolveOpt, summary func(string, builder.SummaryStatusCode)) (*client.SolveResponse, error) {
+	c, err := client.New(ctx, b.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to buildkitd at %s: %v", b.Addr, err)
+	}
+	defer c.Close()
+
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
+
+	statusc := make(chan *client.SolveStatus)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		displayStatus(w, statusc)
+	}()
+	// Solve closes statusc once the build has finished.
+	resp, err := c.Solve(ctx, nil, opt, statusc)
+	<-done
+	return resp, err
+}
+
+// displayStatus writes one line per build step as it starts and completes, followed
+// by the output of the step.
+func displayStatus(w io.Writer, statusc <-chan *client.SolveStatus) {
+	started := make(map[string]bool)
+	completed := make(map[string]bool)
+	for status := range statusc {
+		for _, v := range status.Vertexes {
+			id := v.Digest.String()
+			if v.Started != nil && !started[id] {
+				started[id] = true
+				fmt.Fprintf(w, "%s\n", v.Name)
+			}
+			if v.Completed != nil && !completed[id] {
+				completed[id] = true
+				switch {
+				case v.Error != "":
+					fmt.Fprintf(w, "ERROR %s: %s\n", v.Name, v.Error)
+				case v.Cached:
+					fmt.Fprintf(w, "CACHED %s\n", v.Name)
+				case v.Started != nil:
+					fmt.Fprintf(w, "DONE %s (%.1fs)\n", v.Name, v.Completed.Sub(*v.Started).Seconds())
+				default:
+					fmt.Fprintf(w, "DONE %s\n", v.Name)
+				}
+			}
+		}
+		for _, l := range status.Logs {
+			w.Write(l.Data)
+		}
+	}
+}
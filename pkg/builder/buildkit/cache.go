@@ -0,0 +1,80 @@
+package buildkit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+)
+
+const (
+	cacheTypeRegistry = "registry"
+	cacheTypeLocal    = "local"
+)
+
+// parseCacheOptions parses the cache-from or cache-to entries of a draft.toml environment.
+//
+// An entry is either a list of comma separated key=value attributes such as
+// "type=registry,ref=myregistry.azurecr.io/myapp:cache,mode=max", a local directory
+// (an absolute path or one starting with "."), or otherwise an image reference of a
+// registry cache. Relative directories are resolved against appDir.
+func parseCacheOptions(entries []string, appDir string, export bool) ([]client.CacheOptionsEntry, error) {
+	var opts []client.CacheOptionsEntry
+	for _, entry := range entries {
+		opt, err := parseCacheOption(entry, appDir, export)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+func parseCacheOption(entry, appDir string, export bool) (client.CacheOptionsEntry, error) {
+	// a local cache is read from "src" and written to "dest".
+	dirKey := "src"
+	if export {
+		dirKey = "dest"
+	}
+	opt := client.CacheOptionsEntry{Attrs: make(map[string]string)}
+	switch {
+	case !strings.Contains(entry, "="):
+		if filepath.IsAbs(entry) || strings.HasPrefix(entry, ".") {
+			opt.Type = cacheTypeLocal
+			opt.Attrs[dirKey] = entry
+		} else {
+			opt.Type = cacheTypeRegistry
+			opt.Attrs["ref"] = entry
+		}
+	default:
+		for _, field := range strings.Split(entry, ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return opt, fmt.Errorf("invalid cache option %q: expected key=value, got %q", entry, field)
+			}
+			if kv[0] == "type" {
+				opt.Type = kv[1]
+				continue
+			}
+			opt.Attrs[kv[0]] = kv[1]
+		}
+	}
+	switch opt.Type {
+	case cacheTypeRegistry:
+		if opt.Attrs["ref"] == "" {
+			return opt, fmt.Errorf("invalid cache option %q: registry caches require a ref", entry)
+		}
+	case cacheTypeLocal:
+		dir := opt.Attrs[dirKey]
+		if dir == "" {
+			return opt, fmt.Errorf("invalid cache option %q: local caches require %s", entry, dirKey)
+		}
+		if !filepath.IsAbs(dir) {
+			opt.Attrs[dirKey] = filepath.Join(appDir, dir)
+		}
+	default:
+		return opt, fmt.Errorf("invalid cache option %q: unknown cache type %q", entry, opt.Type)
+	}
+	return opt, nil
+}
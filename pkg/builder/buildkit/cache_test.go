@@ -0,0 +1,52 @@
+package buildkit
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/moby/buildkit/client"
+)
+
+func TestParseCacheOption(t *testing.T) {
+	appDir := filepath.Join("testdata", "app")
+	var tests = []struct {
+		in       string
+		export   bool
+		expected client.CacheOptionsEntry
+	}{
+		{
+			"myregistry.azurecr.io/myapp:cache", false,
+			client.CacheOptionsEntry{Type: "registry", Attrs: map[string]string{"ref": "myregistry.azurecr.io/myapp:cache"}},
+		},
+		{
+			"./.cache", true,
+			client.CacheOptionsEntry{Type: "local", Attrs: map[string]string{"dest": filepath.Join(appDir, ".cache")}},
+		},
+		{
+			"./.cache", false,
+			client.CacheOptionsEntry{Type: "local", Attrs: map[string]string{"src": filepath.Join(appDir, ".cache")}},
+		},
+		{
+			"type=registry,ref=myapp:cache,mode=max", true,
+			client.CacheOptionsEntry{Type: "registry", Attrs: map[string]string{"ref": "myapp:cache", "mode": "max"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			actual, err := parseCacheOption(tt.in, appDir, tt.export)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %+v but got %+v", tt.expected, actual)
+			}
+		})
+	}
+
+	for _, in := range []string{"type=registry", "type=s3,bucket=foo", "type=local,dest=foo", "type=registry,ref"} {
+		if _, err := parseCacheOption(in, appDir, false); err == nil {
+			t.Errorf("expected %q to be invalid", in)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package builder
+
+import (
+	"fmt"
+	"log"
+
+	"golang.org/x/net/context"
+)
+
+// ImageFinder is implemented by container builders which can tell whether the image of a
+// build context has been built before, so that building and pushing it can be skipped.
+type ImageFinder interface {
+	// FindImage reports whether every image of app already exists and refers to the same
+	// image, in the registry or locally if no registry is configured. The digest of the
+	// image is set on app when it is known.
+	FindImage(ctx context.Context, app *AppContext) (bool, error)
+}
+
+// imageExists reports whether the image tagged with the checksum of the build context
+// already exists, reporting the build stage as cached if it does.
+//
+// Images are always built when they are written to an image archive, which needs the image
+// to be available to the container builder.
+func (b *Builder) imageExists(ctx context.Context, app *AppContext, out chan<- *Summary) bool {
+	finder, ok := b.ContainerBuilder.(ImageFinder)
+	if !ok || app.Ctx.ImageArchivePath() != "" {
+		return false
+	}
+	exists, err := finder.FindImage(ctx, app)
+	if err != nil {
+		// the image is rebuilt; a failed lookup only costs the time of the build.
+		log.Printf("could not look up image %s: %v\n", app.MainImage, err)
+		return false
+	}
+	if !exists {
+		return false
+	}
+	summary := Summarize(app.ID, "Building Docker Image", out)
+	summary("started", SummaryStarted)
+	summary(fmt.Sprintf("image %s already exists", app.MainImage), SummaryCached)
+	return true
+}
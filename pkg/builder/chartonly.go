@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/storage"
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/strvals"
+)
+
+// LatestImageBuild returns the most recent successful build which built an image, or nil
+// if there is none.
+func LatestImageBuild(builds []*storage.Object) *storage.Object {
+	sorted := make([]*storage.Object, len(builds))
+	copy(sorted, builds)
+	storage.SortByCreatedAt(sorted)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if succeeded(sorted[i]) && len(sorted[i].GetImages()) > 0 {
+			return sorted[i]
+		}
+	}
+	return nil
+}
+
+// reuseImage points app at the image of the latest successful build of the application
+// instead of building a new one, reporting the build stage as cached.
+func (b *Builder) reuseImage(ctx context.Context, app *AppContext, out chan<- *Summary) (err error) {
+	const stageDesc = "Building Docker Image"
+
+	summary := Summarize(app.ID, stageDesc, out)
+	defer func() {
+		if err != nil {
+			Complete(app.ID, stageDesc, out, &err)
+		}
+	}()
+
+	// notify that particular stage has started.
+	summary("started", SummaryStarted)
+
+	builds, err := b.Storage.GetBuilds(ctx, app.Ctx.Env.Name)
+	if err != nil {
+		return fmt.Errorf("could not retrieve the builds of %s: %v", app.Ctx.Env.Name, err)
+	}
+	build := LatestImageBuild(builds)
+	if build == nil {
+		return ErrNoImageToReuse
+	}
+
+	if len(app.Services) == 0 {
+		repository, tag := splitImage(build.Images[0])
+		if err := strvals.ParseInto(fmt.Sprintf("image.repository=%s,image.tag=%s", repository, tag), app.Vals); err != nil {
+			return err
+		}
+		app.MainImage = build.Images[0]
+		app.Digest = build.ImageDigest
+	} else {
+		// the image of each service is the first one of the build in the service's repository.
+		for _, svc := range app.Services {
+			repository, _ := splitImage(svc.MainImage)
+			image := repositoryImage(build.Images, repository)
+			if image == "" {
+				return fmt.Errorf("build %s has no image for service %s: %v", build.BuildID, svc.Ctx.Service, ErrNoImageToReuse)
+			}
+			_, tag := splitImage(image)
+			if err := strvals.ParseInto(fmt.Sprintf("%[1]s.image.repository=%[2]s,%[1]s.image.tag=%[3]s", svc.Ctx.Service, repository, tag), app.Vals); err != nil {
+				return err
+			}
+			svc.MainImage, svc.Images = image, []string{image}
+			svc.Digest = build.ImageDigests[image]
+		}
+		app.MainImage = app.Services[0].MainImage
+	}
+	app.Images = build.Images
+	app.Obj.Images = build.Images
+	app.Obj.ContextID = build.ContextID
+
+	summary(fmt.Sprintf("reusing image %s of build %s", app.MainImage, build.BuildID), SummaryCached)
+	return nil
+}
+
+// repositoryImage returns the first of images in the given repository, or "" if there is none.
+func repositoryImage(images []string, repository string) string {
+	for _, image := range images {
+		if r, _ := splitImage(image); r == repository {
+			return image
+		}
+	}
+	return ""
+}
+
+// chartOnly reports whether the changed files only affect how the chart is deployed: the
+// chart itself, or the values set in draft.toml. The image of the previous build can be
+// reused for such changes.
+//
+// prev and next are the environment as read from draft.toml before and after the changes.
+func chartOnly(appDir string, prev, next *manifest.Environment, changes []string) bool {
+	chartDir := filepath.Join(appDir, deployDir(next))
+	for _, path := range changes {
+		switch {
+		case path == chartDir || strings.HasPrefix(path, chartDir+string(filepath.Separator)):
+		case path == filepath.Join(appDir, "draft.toml"):
+			before, after := *prev, *next
+			before.Values, after.Values = nil, nil
+			if !reflect.DeepEqual(before, after) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return len(changes) > 0
+}
@@ -0,0 +1,117 @@
+package builder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/Azure/draft/pkg/storage/inprocess"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+func TestLatestImageBuild(t *testing.T) {
+	build := func(id, status string, age time.Duration, images ...string) *storage.Object {
+		created, _ := ptypes.TimestampProto(time.Now().Add(-age))
+		return &storage.Object{BuildID: id, Status: status, Images: images, CreatedAt: created}
+	}
+	builds := []*storage.Object{
+		build("foo1", "success", 4*time.Minute, "example/app:foo1"),
+		build("foo4", "failure", 1*time.Minute, "example/app:foo4"),
+		build("foo3", "success", 2*time.Minute),
+		build("foo2", "success", 3*time.Minute, "example/app:foo2"),
+	}
+
+	latest := LatestImageBuild(builds)
+	if latest == nil {
+		t.Fatal("expected a build to reuse the image of")
+	}
+	if latest.BuildID != "foo2" {
+		t.Errorf("expected latest image build %q, got %q", "foo2", latest.BuildID)
+	}
+	if builds[0].BuildID != "foo1" {
+		t.Error("expected the builds not to be reordered")
+	}
+
+	if latest := LatestImageBuild(builds[1:3]); latest != nil {
+		t.Errorf("expected no build to reuse the image of, got %q", latest.BuildID)
+	}
+}
+
+func TestReuseImageServices(t *testing.T) {
+	b := &Builder{ID: "foo2", Storage: inprocess.NewStore()}
+	prev := &storage.Object{
+		BuildID:      "foo1",
+		Status:       "success",
+		Images:       []string{"app-api:foo1", "app-web:foo1"},
+		ImageDigests: map[string]string{"app-api:foo1": "sha256:api"},
+	}
+	if err := b.Storage.CreateBuild(context.Background(), "app", prev); err != nil {
+		t.Fatal(err)
+	}
+
+	service := func(name string) *AppContext {
+		return &AppContext{MainImage: "app-" + name + ":foo2", Ctx: &Context{Service: name}}
+	}
+	app := &AppContext{
+		ID:       b.ID,
+		Obj:      &storage.Object{},
+		Vals:     chartutil.Values{},
+		Ctx:      &Context{Env: &manifest.Environment{Name: "app"}},
+		Services: []*AppContext{service("api"), service("web")},
+	}
+	out := make(chan *Summary, 10)
+	if err := b.reuseImage(context.Background(), app, out); err != nil {
+		t.Fatal(err)
+	}
+	api, web := app.Services[0], app.Services[1]
+	if api.MainImage != "app-api:foo1" || api.Digest != "sha256:api" {
+		t.Errorf("expected image %s at %s, got %s at %s", "app-api:foo1", "sha256:api", api.MainImage, api.Digest)
+	}
+	if web.MainImage != "app-web:foo1" || web.Digest != "" {
+		t.Errorf("expected image %s without digest, got %s at %q", "app-web:foo1", web.MainImage, web.Digest)
+	}
+}
+
+func TestChartOnly(t *testing.T) {
+	appDir := filepath.FromSlash("/src/app")
+	path := func(p string) string {
+		return filepath.Join(appDir, filepath.FromSlash(p))
+	}
+	env := &manifest.Environment{Name: "app", Values: []string{"replicaCount=1"}}
+	withValues := &manifest.Environment{Name: "app", Values: []string{"replicaCount=2"}}
+	withArgs := &manifest.Environment{Name: "app", Values: []string{"replicaCount=1"}, ImageBuildArgs: map[string]string{"foo": "bar"}}
+	withChart := &manifest.Environment{Name: "app", Chart: "deploy/app"}
+
+	var chartOnlyTests = []struct {
+		name      string
+		next      *manifest.Environment
+		changes   []string
+		chartOnly bool
+	}{
+		{"chart", env, []string{path("charts/app/values.yaml")}, true},
+		{"chart and source", env, []string{path("charts/app/values.yaml"), path("main.go")}, false},
+		{"source", env, []string{path("main.go")}, false},
+		{"chart dir prefix", env, []string{path("charts-old/app/values.yaml")}, false},
+		{"values", withValues, []string{path("draft.toml")}, true},
+		{"build args", withArgs, []string{path("draft.toml")}, false},
+		{"custom chart", withChart, []string{path("deploy/app/templates/deployment.yaml")}, true},
+		{"custom chart ignores charts", withChart, []string{path("charts/app/values.yaml")}, false},
+		{"no changes", env, nil, false},
+	}
+
+	for _, tt := range chartOnlyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := env
+			if tt.next.Chart != "" {
+				prev = tt.next
+			}
+			if actual := chartOnly(appDir, prev, tt.next, tt.changes); actual != tt.chartOnly {
+				t.Errorf("expected %v but got %v", tt.chartOnly, actual)
+			}
+		})
+	}
+}
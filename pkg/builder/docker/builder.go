@@ -1,12 +1,15 @@
 package docker
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/Azure/draft/pkg/builder"
 	"github.com/docker/cli/cli/command"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -29,81 +32,91 @@ func (b *Builder) Build(ctx context.Context, app *builder.AppContext, out chan<-
 	// notify that particular stage has started.
 	summary("started", builder.SummaryStarted)
 
-	msgc := make(chan string)
-	errc := make(chan error)
+	errc := make(chan error, 1)
 	go func() {
-		args := make(map[string]*string)
-		for k := range app.Ctx.Env.ImageBuildArgs {
-			v := app.Ctx.Env.ImageBuildArgs[k]
-			args[k] = &v
-		}
+		errc <- b.build(ctx, app, summary)
+	}()
+	return builder.Await(errc, summary)
+}
 
-		authToken, err := b.AuthToken(ctx, app)
-		if err != nil {
-			return
+func (b *Builder) build(ctx context.Context, app *builder.AppContext, summary func(string, builder.SummaryStatusCode)) error {
+	if !builder.MultiPlatform(app.Ctx.Env) {
+		var platform string
+		if len(app.Ctx.Env.Platforms) == 1 {
+			platform = app.Ctx.Env.Platforms[0]
 		}
-
-		// we need to translate the auth token Docker gives us into a Kubernetes registry auth secret token.
-		regAuth, err := builder.FromAuthConfigToken(authToken)
-		if err != nil {
-			return
+		return b.buildImage(ctx, app, app.Images, platform, summary)
+	}
+	// the manifest list covering every platform is assembled in the registry once pushed.
+	if app.Ctx.Env.Registry == "" {
+		return builder.ErrMultiPlatformRegistry
+	}
+	for _, platform := range app.Ctx.Env.Platforms {
+		summary("building image for "+platform, builder.SummaryLogging)
+		if err := b.buildImage(ctx, app, []string{builder.PlatformImage(app.MainImage, platform)}, platform, summary); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// buildImage builds the application image for platform, or for the platform of the docker
+// daemon if empty, and tags it with each of tags.
+func (b *Builder) buildImage(ctx context.Context, app *builder.AppContext, tags []string, platform string, summary func(string, builder.SummaryStatusCode)) error {
+	args := make(map[string]*string)
+	for k := range app.Ctx.Env.ImageBuildArgs {
+		v := app.Ctx.Env.ImageBuildArgs[k]
+		args[k] = &v
+	}
 
-		buildopts := types.ImageBuildOptions{
-			Tags:       app.Images,
-			Dockerfile: app.Ctx.Env.Dockerfile,
-			BuildArgs:  args,
-			AuthConfigs: map[string]types.AuthConfig{
-				regAuth.ServerAddress: {
-					Username:      regAuth.Username,
-					Password:      regAuth.Password,
-					ServerAddress: regAuth.ServerAddress,
-				},
+	authToken, err := b.AuthToken(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	// we need to translate the auth token Docker gives us into a Kubernetes registry auth secret token.
+	regAuth, err := builder.FromAuthConfigToken(authToken)
+	if err != nil {
+		return err
+	}
+
+	buildopts := types.ImageBuildOptions{
+		Tags:       tags,
+		Dockerfile: app.Ctx.Env.Dockerfile,
+		BuildArgs:  args,
+		Platform:   platform,
+		AuthConfigs: map[string]types.AuthConfig{
+			regAuth.ServerAddress: {
+				Username:      regAuth.Username,
+				Password:      regAuth.Password,
+				ServerAddress: regAuth.ServerAddress,
 			},
-		}
+		},
+	}
 
-		resp, err := b.DockerClient.Client().ImageBuild(ctx, app.Buf, buildopts)
-		if err != nil {
-			errc <- err
-			return
-		}
-		defer func() {
-			resp.Body.Close()
-			close(msgc)
-			close(errc)
-		}()
-		outFd, isTerm := term.GetFdInfo(app.Buf)
-		if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, app.Log, outFd, isTerm, nil); err != nil {
-			errc <- err
-			return
-		}
-		if _, _, err = b.DockerClient.Client().ImageInspectWithRaw(ctx, app.MainImage); err != nil {
-			if dockerclient.IsErrNotFound(err) {
-				errc <- fmt.Errorf("Could not locate image for %s: %v", app.Ctx.Env.Name, err)
-				return
-			}
-			errc <- fmt.Errorf("ImageInspectWithRaw error: %v", err)
-			return
-		}
-	}()
-	for msgc != nil || errc != nil {
-		select {
-		case msg, ok := <-msgc:
-			if !ok {
-				msgc = nil
-				continue
-			}
-			summary(msg, builder.SummaryLogging)
-		case err, ok := <-errc:
-			if !ok {
-				errc = nil
-				continue
-			}
-			return err
-		default:
-			summary("ongoing", builder.SummaryOngoing)
-			time.Sleep(time.Second)
+	src, err := app.Ctx.Archive.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	resp, err := b.DockerClient.Client().ImageBuild(ctx, src, buildopts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
+	outFd, isTerm := term.GetFdInfo(w)
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, w, outFd, isTerm, nil); err != nil {
+		return err
+	}
+	if _, _, err = b.DockerClient.Client().ImageInspectWithRaw(ctx, tags[0]); err != nil {
+		if dockerclient.IsErrNotFound(err) {
+			return fmt.Errorf("Could not locate image for %s: %v", app.Ctx.Env.Name, err)
 		}
+		return fmt.Errorf("ImageInspectWithRaw error: %v", err)
 	}
 	return nil
 }
@@ -122,66 +135,153 @@ func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<-
 	// notify that particular stage has started.
 	summary("started", builder.SummaryStarted)
 
-	msgc := make(chan string, 1)
 	errc := make(chan error, 1)
+	go func() {
+		errc <- b.push(ctx, app, summary)
+	}()
+	return builder.Await(errc, summary)
+}
 
-	var wg sync.WaitGroup
-	wg.Add(len(app.Images))
+// push pushes every tag of the application image concurrently, returning the first error.
+// The images of a multi-platform build are pushed under their own tags, followed by the
+// manifest list referencing them under every tag of the application image.
+func (b *Builder) push(ctx context.Context, app *builder.AppContext, summary func(string, builder.SummaryStatusCode)) error {
+	registryAuth, err := command.RetrieveAuthTokenFromImage(ctx, b.DockerClient, app.MainImage)
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		registryAuth, err := command.RetrieveAuthTokenFromImage(ctx, b.DockerClient, app.MainImage)
-		if err != nil {
-			errc <- err
-			return
+	tags := app.Images
+	if builder.MultiPlatform(app.Ctx.Env) {
+		tags = nil
+		for _, platform := range app.Ctx.Env.Platforms {
+			tags = append(tags, builder.PlatformImage(app.MainImage, platform))
 		}
+	}
 
-		for _, tag := range app.Images {
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
 
-			go func(tag string) {
-				defer wg.Done()
+	errc := make(chan error, len(tags))
+	var wg sync.WaitGroup
+	wg.Add(len(tags))
+	for _, tag := range tags {
+		go func(tag string) {
+			defer wg.Done()
 
-				resp, err := b.DockerClient.Client().ImagePush(ctx, tag, types.ImagePushOptions{RegistryAuth: registryAuth})
-				if err != nil {
-					errc <- err
-					return
-				}
+			resp, err := b.DockerClient.Client().ImagePush(ctx, tag, types.ImagePushOptions{RegistryAuth: registryAuth})
+			if err != nil {
+				errc <- err
+				return
+			}
 
-				defer resp.Close()
-				outFd, isTerm := term.GetFdInfo(app.Log)
-				if err := jsonmessage.DisplayJSONMessagesStream(resp, app.Log, outFd, isTerm, nil); err != nil {
-					errc <- err
-					return
-				}
-			}(tag)
-		}
+			defer resp.Close()
+			outFd, isTerm := term.GetFdInfo(w)
+			if err := jsonmessage.DisplayJSONMessagesStream(resp, w, outFd, isTerm, nil); err != nil {
+				errc <- err
+				return
+			}
+		}(tag)
+	}
+	wg.Wait()
+	close(errc)
+	if err := <-errc; err != nil {
+		return err
+	}
+	if builder.MultiPlatform(app.Ctx.Env) {
+		summary("pushing manifest list for "+strings.Join(app.Ctx.Env.Platforms, ", "), builder.SummaryLogging)
+		app.Digest, err = builder.PushManifestList(ctx, registryAuth, app.Images, app.Ctx.Env.Platforms)
+		return err
+	}
+	app.Digest, err = b.repoDigest(ctx, app.MainImage)
+	return err
+}
 
-		defer func() {
-			close(errc)
-			close(msgc)
-		}()
+// repoDigest returns the digest of the manifest the registry stored for the pushed image.
+func (b *Builder) repoDigest(ctx context.Context, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	inspect, _, err := b.DockerClient.Client().ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", fmt.Errorf("ImageInspectWithRaw error: %v", err)
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		ref, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+		if canonical, ok := ref.(reference.Canonical); ok && ref.Name() == named.Name() {
+			return canonical.Digest().String(), nil
+		}
+	}
+	return "", fmt.Errorf("could not find the digest of %s in %s", image, named.Name())
+}
 
-	}()
-	for msgc != nil || errc != nil {
-		select {
-		case msg, ok := <-msgc:
-			if !ok {
-				msgc = nil
-				continue
+// FindImage reports whether every image built for app exists and refers to the same image,
+// in the registry or in the docker daemon if no registry is configured.
+func (b *Builder) FindImage(ctx context.Context, app *builder.AppContext) (bool, error) {
+	if app.Ctx.Env.Registry == "" {
+		var id string
+		for _, image := range app.Images {
+			inspect, _, err := b.DockerClient.Client().ImageInspectWithRaw(ctx, image)
+			if err != nil {
+				if dockerclient.IsErrNotFound(err) {
+					return false, nil
+				}
+				return false, err
 			}
-			summary(msg, builder.SummaryLogging)
-		case err, ok := <-errc:
-			if !ok {
-				errc = nil
-				continue
+			if id != "" && inspect.ID != id {
+				return false, nil
 			}
-			return err
-		default:
-			summary("ongoing", builder.SummaryOngoing)
-			time.Sleep(time.Second)
+			id = inspect.ID
 		}
+		return true, nil
 	}
-	wg.Wait()
-	return nil
+
+	registryAuth, err := command.RetrieveAuthTokenFromImage(ctx, b.DockerClient, app.MainImage)
+	if err != nil {
+		return false, err
+	}
+	var digest string
+	for _, image := range app.Images {
+		// the registry does not tell unknown images apart from other errors, so any error
+		// means the image is built and pushed, which reports actual problems.
+		inspect, err := b.DockerClient.Client().DistributionInspect(ctx, image, registryAuth)
+		if err != nil {
+			return false, nil
+		}
+		if digest != "" && inspect.Descriptor.Digest.String() != digest {
+			return false, nil
+		}
+		digest = inspect.Descriptor.Digest.String()
+	}
+	app.Digest = digest
+	return true, nil
+}
+
+// SaveImage writes the images built for app to w in the format of `docker save`.
+func (b *Builder) SaveImage(ctx context.Context, app *builder.AppContext, w io.Writer, format string, summary func(string, builder.SummaryStatusCode)) (string, error) {
+	if format != builder.ImageArchiveDocker {
+		return "", fmt.Errorf("the docker container builder cannot write %s image archives", format)
+	}
+	if builder.MultiPlatform(app.Ctx.Env) {
+		return "", errors.New("the docker container builder cannot write image archives of images built for several platforms")
+	}
+	rc, err := b.DockerClient.Client().ImageSave(ctx, app.Images)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(w, rc); err != nil {
+		return "", err
+	}
+	inspect, _, err := b.DockerClient.Client().ImageInspectWithRaw(ctx, app.MainImage)
+	if err != nil {
+		return "", fmt.Errorf("ImageInspectWithRaw error: %v", err)
+	}
+	return inspect.ID, nil
 }
 
 // AuthToken retrieves the auth token for the given image.
@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/ghodss/yaml"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/timeconv"
+)
+
+// DryRun writes what Up would release for bctx to w without building, pushing or releasing
+// anything, nor recording a build: the values the chart is rendered with, merged with the
+// values of the chart, followed by the manifest rendered from it. Applications deployed from
+// plain manifests or a kustomization have their objects written instead, as they would be
+// applied.
+//
+// The images are named after the build context, as they would be tagged by Up. Since no
+// image is pushed, the values and objects reference images by tag rather than digest. A dry
+// run cannot reuse the image of a previous build, so chart-only contexts are rejected.
+func (b *Builder) DryRun(bctx *Context, w io.Writer) error {
+	if bctx.ChartOnly {
+		return errors.New("a dry run renders the images of the build context and cannot reuse the image of a previous build")
+	}
+	app, err := newAppContext(b, bctx)
+	if err != nil {
+		return err
+	}
+
+	if bctx.Objects != nil {
+		objs, err := prepareObjects(bctx.Objects, imageReferences(app), objectLabels(app))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "MANIFEST:")
+		for _, obj := range objs {
+			data, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return fmt.Errorf("could not encode %s %s: %v", obj.GetKind(), obj.GetName(), err)
+			}
+			fmt.Fprintf(w, "---\n%s", data)
+		}
+		return nil
+	}
+
+	vals, err := app.Vals.YAML()
+	if err != nil {
+		return err
+	}
+	config := &chart.Config{Raw: vals}
+	// the templates see the values of the chart overridden by those of the release.
+	computed, err := chartutil.CoalesceValues(bctx.Chart, config)
+	if err != nil {
+		return err
+	}
+	computedYAML, err := computed.YAML()
+	if err != nil {
+		return err
+	}
+	namespace := bctx.Env.Namespace
+	if namespace == "" {
+		namespace = manifest.DefaultNamespace
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      bctx.Env.Name,
+		Namespace: namespace,
+		Time:      timeconv.Now(),
+		Revision:  1,
+		IsInstall: true,
+	}
+	// without a cluster to ask, templates see the default capabilities helm renders with.
+	caps := &chartutil.Capabilities{
+		APIVersions: chartutil.DefaultVersionSet,
+		KubeVersion: chartutil.DefaultKubeVersion,
+	}
+	rendered, _, err := RenderChart(bctx.Chart, config, options, caps)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "COMPUTED VALUES:\n%s\nMANIFEST:\n%s", computedYAML, rendered)
+	return nil
+}
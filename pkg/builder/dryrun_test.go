@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+const dryRunTemplate = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+`
+
+func TestDryRun(t *testing.T) {
+	ctx := &Context{
+		Env:     &manifest.Environment{Name: "app", Registry: "example.azurecr.io"},
+		Archive: &Archive{digest: []byte{0xde, 0xad, 0xbe, 0xef}},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{Name: "app"},
+			Templates: []*chart.Template{
+				{Name: "templates/deployment.yaml", Data: []byte(dryRunTemplate)},
+				{Name: "templates/test.yaml", Data: []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: test\n  annotations:\n    helm.sh/hook: test-success\n")},
+			},
+			Values: &chart.Config{Raw: "replicaCount: 1\nimage:\n  pullPolicy: IfNotPresent\n"},
+		},
+		Values: &chart.Config{Raw: "replicaCount: 3\n"},
+	}
+	b := &Builder{ID: "01CBKJ4AE6GB4Y"}
+
+	var out bytes.Buffer
+	if err := b.DryRun(ctx, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"COMPUTED VALUES:\n",
+		"buildID: 01CBKJ4AE6GB4Y\n",
+		"replicaCount: 3\n",
+		"pullPolicy: IfNotPresent\n",
+		"repository: example.azurecr.io/app\n",
+		"MANIFEST:\n---\n# Source: app/templates/deployment.yaml\n",
+		"  name: app\n  namespace: default\n",
+		`image: "example.azurecr.io/app:deadbeef"`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "helm.sh/hook") {
+		t.Errorf("expected hooks to be left out, got:\n%s", out.String())
+	}
+
+	ctx.ChartOnly = true
+	if err := b.DryRun(ctx, &out); err == nil {
+		t.Error("expected a chart-only dry run to fail")
+	}
+}
+
+func TestDryRunObjects(t *testing.T) {
+	ctx := &Context{
+		AppDir:  filepath.Join("testdata", "manifests"),
+		Env:     &manifest.Environment{Name: "app", Manifests: "k8s", Registry: "example.azurecr.io"},
+		Archive: &Archive{digest: []byte{0xde, 0xad, 0xbe, 0xef}},
+	}
+	objs, err := loadObjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Objects = objs
+	b := &Builder{ID: "01CBKJ4AE6GB4Y"}
+
+	var out bytes.Buffer
+	if err := b.DryRun(ctx, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "MANIFEST:\n---\n") {
+		t.Errorf("expected the objects to follow the manifest header, got:\n%s", out.String())
+	}
+	if n := strings.Count(out.String(), "\n---\n"); n != len(objs) {
+		t.Errorf("expected %d objects, got %d", len(objs), n)
+	}
+	for _, expected := range []string{"buildID: 01CBKJ4AE6GB4Y", "image: example.azurecr.io/app:deadbeef"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+}
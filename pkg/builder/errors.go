@@ -7,4 +7,14 @@ var (
 	ErrChartNotExist = errors.New("chart/ does not exist. Please create it using 'draft create' before calling 'draft up'")
 	// ErrDockerfileNotExist is returned when no Dockerfile exists during "draft up."
 	ErrDockerfileNotExist = errors.New("Dockerfile does not exist. Please create it using 'draft create' before calling 'draft up'")
+	// ErrNoRollbackTarget is returned when there is no previous successful build to roll back to.
+	ErrNoRollbackTarget = errors.New("no previous successful build to roll back to")
+	// ErrNoImageToReuse is returned when deploying only the chart without a previous successful build.
+	ErrNoImageToReuse = errors.New("no previous successful build to reuse the image of. Please run 'draft up' without --chart-only first")
+	// ErrRollbackObjects is returned when rolling back an application deployed from plain manifests or a kustomization.
+	ErrRollbackObjects = errors.New("rolling back is only supported for applications deployed from a chart")
+	// ErrReleaseNotFound is returned by release backends when an application has not been released yet.
+	ErrReleaseNotFound = errors.New("release not found")
+	// ErrMultiPlatformRegistry is returned when building an image for several platforms without a registry to push the manifest list to.
+	ErrMultiPlatformRegistry = errors.New("building an image for several platforms requires a registry to push the manifest list to")
 )
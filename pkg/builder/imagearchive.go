@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/draft/pkg/osutil"
+	"golang.org/x/net/context"
+)
+
+const (
+	// ImageArchiveDocker is the image archive format written by `docker save`.
+	ImageArchiveDocker = "docker"
+	// ImageArchiveOCI is the OCI image layout archive format.
+	ImageArchiveOCI = "oci"
+)
+
+// ImageSaver is implemented by container builders which can write the built image to a
+// tarball, so that it can be loaded onto cluster nodes without going through a registry.
+type ImageSaver interface {
+	// SaveImage writes the images built for app to w in the given archive format,
+	// returning the digest or ID of the saved image.
+	SaveImage(ctx context.Context, app *AppContext, w io.Writer, format string, summary func(string, SummaryStatusCode)) (string, error)
+}
+
+// ImageArchivePath returns the absolute path of the image archive configured for the
+// environment, or "" if the image is not written to an archive.
+//
+// Relative paths are resolved against the application directory.
+func (ctx *Context) ImageArchivePath() string {
+	path := ctx.Env.ImageArchive
+	if path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.AppDir, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// saveImage writes the built image to the image archive configured for the environment.
+func (b *Builder) saveImage(ctx context.Context, app *AppContext, out chan<- *Summary) (err error) {
+	path := app.Ctx.ImageArchivePath()
+	if path == "" {
+		return nil
+	}
+
+	const stageDesc = "Saving Docker Image"
+
+	defer Complete(app.ID, stageDesc, out, &err)
+	summary := Summarize(app.ID, stageDesc, out)
+
+	// notify that particular stage has started.
+	summary("started", SummaryStarted)
+
+	format := app.Ctx.Env.ImageArchiveFormat
+	if format == "" {
+		format = ImageArchiveDocker
+	}
+	if format != ImageArchiveDocker && format != ImageArchiveOCI {
+		return fmt.Errorf("unknown image archive format %q (supported values: %s, %s)", format, ImageArchiveDocker, ImageArchiveOCI)
+	}
+	saver, ok := b.ContainerBuilder.(ImageSaver)
+	if !ok {
+		return fmt.Errorf("container builder %q cannot write image archives", app.Ctx.Env.ContainerBuilder)
+	}
+
+	if err := osutil.EnsureDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	// the archive is hashed as it is written, so that the recorded digest identifies the
+	// archive itself rather than the image in it.
+	hash := sha256.New()
+	var digest string
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		digest, err = saver.SaveImage(ctx, app, io.MultiWriter(f, hash), format, summary)
+		errc <- err
+	}()
+	err = Await(errc, summary)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// do not leave a truncated archive behind to be side-loaded by mistake.
+		os.Remove(path)
+		return fmt.Errorf("could not write image archive %s: %v", path, err)
+	}
+
+	app.Obj.ImageArchiveRef = path
+	app.Obj.ImageArchiveDigest = fmt.Sprintf("sha256:%x", hash.Sum(nil))
+	summary(fmt.Sprintf("saved %s to %s (%s)", digest, path, app.Obj.ImageArchiveDigest), SummaryLogging)
+	return nil
+}
@@ -0,0 +1,392 @@
+package incluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/draft/pkg/builder"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// DefaultImage is the daemonless builder image run in the build pod.
+	DefaultImage = "gcr.io/kaniko-project/executor:latest"
+
+	containerName = "kaniko"
+	dockerDir     = "/kaniko/.docker"
+	// terminationLog is the default termination message path of a container.
+	terminationLog = "/dev/termination-log"
+	pollInterval   = time.Second
+)
+
+// Builder builds container images in a short-lived pod running a daemonless builder
+// in the target namespace, so no local docker daemon is required.
+type Builder struct {
+	Kube       k8s.Interface
+	RESTConfig *rest.Config
+	// Image is the builder image run in the build pod. Defaults to DefaultImage.
+	Image string
+}
+
+// Build builds the docker image and pushes it to the registry.
+func (b *Builder) Build(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) (err error) {
+	const stageDesc = "Building Docker Image"
+
+	defer builder.Complete(app.ID, stageDesc, out, &err)
+	summary := builder.Summarize(app.ID, stageDesc, out)
+
+	// notify that particular stage has started.
+	summary("started", builder.SummaryStarted)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- b.build(ctx, app, summary)
+	}()
+	return builder.Await(errc, summary)
+}
+
+func (b *Builder) build(ctx context.Context, app *builder.AppContext, summary func(string, builder.SummaryStatusCode)) error {
+	namespace := app.Ctx.Env.Namespace
+	if err := builder.EnsureNamespace(b.Kube, namespace); err != nil {
+		return err
+	}
+
+	multi := builder.MultiPlatform(app.Ctx.Env)
+	push := app.Ctx.Env.Registry != ""
+	// the manifest list covering every platform is assembled in the registry once pushed.
+	if multi && !push {
+		return builder.ErrMultiPlatformRegistry
+	}
+
+	name := buildName(app)
+	var authToken string
+	if push {
+		var err error
+		authToken, err = b.AuthToken(ctx, app)
+		if err != nil {
+			return err
+		}
+		config, err := builder.DockerConfigJSON(app.Ctx.Env.Registry, authToken)
+		if err != nil {
+			return err
+		}
+		if _, err := b.Kube.CoreV1().Secrets(namespace).Create(newSecret(app, name, config)); err != nil {
+			return fmt.Errorf("could not create build secret: %v", err)
+		}
+		defer b.Kube.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+	}
+
+	if !multi {
+		var platform string
+		if len(app.Ctx.Env.Platforms) == 1 {
+			platform = app.Ctx.Env.Platforms[0]
+		}
+		pod, err := b.runPod(ctx, app, newPod(app, name, b.image(), app.Images, platform, push), summary)
+		if err != nil {
+			return err
+		}
+		if push {
+			app.Digest = pushedDigest(pod)
+		}
+		return nil
+	}
+
+	// each platform is built by a pod of its own, scheduled on a node of that platform.
+	for _, platform := range app.Ctx.Env.Platforms {
+		podName := name + "-" + strings.ToLower(strings.Replace(platform, "/", "-", -1))
+		tags := []string{builder.PlatformImage(app.MainImage, platform)}
+		summary("building image for "+platform, builder.SummaryLogging)
+		if _, err := b.runPod(ctx, app, newPod(app, podName, b.image(), tags, platform, push), summary); err != nil {
+			return err
+		}
+	}
+	digest, err := builder.PushManifestList(ctx, authToken, app.Images, app.Ctx.Env.Platforms)
+	if err != nil {
+		return err
+	}
+	app.Digest = digest
+	return nil
+}
+
+// runPod creates the build pod, streams the build context to it and waits for the build to
+// finish, returning the terminated pod.
+func (b *Builder) runPod(ctx context.Context, app *builder.AppContext, pod *v1.Pod, summary func(string, builder.SummaryStatusCode)) (*v1.Pod, error) {
+	namespace, name := app.Ctx.Env.Namespace, pod.Name
+	pods := b.Kube.CoreV1().Pods(namespace)
+	if _, err := pods.Create(pod); err != nil {
+		return nil, fmt.Errorf("could not create build pod: %v", err)
+	}
+	defer pods.Delete(name, &metav1.DeleteOptions{})
+
+	summary("waiting for build pod "+name, builder.SummaryLogging)
+	pod, err := b.waitForPod(ctx, namespace, name, func(pod *v1.Pod) bool {
+		return pod.Status.Phase != v1.PodPending || stuckReason(pod) != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase == v1.PodPending {
+		return nil, fmt.Errorf("build pod %s cannot start: %s", name, stuckReason(pod))
+	}
+
+	if pod.Status.Phase == v1.PodRunning {
+		if err := b.attach(ctx, app, namespace, name, summary); err != nil {
+			if err == ctx.Err() {
+				return nil, err
+			}
+			return nil, fmt.Errorf("could not stream the build context to the build pod: %v", err)
+		}
+		pod, err = b.waitForPod(ctx, namespace, name, func(pod *v1.Pod) bool {
+			return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pod.Status.Phase != v1.PodSucceeded {
+		return nil, fmt.Errorf("build pod %s failed: %s", name, failureReason(pod))
+	}
+	return pod, nil
+}
+
+// attach sends the build context archive to the builder's stdin and streams its
+// output into the build log. The stream cannot be cancelled, so the build pod is
+// deleted to end it when ctx is cancelled.
+func (b *Builder) attach(ctx context.Context, app *builder.AppContext, namespace, name string, summary func(string, builder.SummaryStatusCode)) error {
+	req := b.Kube.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(name).
+		SubResource("attach").
+		VersionedParams(&v1.PodAttachOptions{
+			Container: containerName,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(b.RESTConfig, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	src, err := app.Ctx.Archive.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.Kube.CoreV1().Pods(namespace).Delete(name, &metav1.DeleteOptions{})
+		case <-done:
+		}
+	}()
+
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:  src,
+		Stdout: w,
+		Stderr: w,
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// waitForPod polls the build pod until done reports true or ctx is cancelled.
+func (b *Builder) waitForPod(ctx context.Context, namespace, name string, done func(*v1.Pod) bool) (*v1.Pod, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := b.Kube.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not get build pod %s: %v", name, err)
+		}
+		if done(pod) {
+			return pod, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push pushes the results of Build to the image repository.
+//
+// It is a no-op reporting no stage of its own: the build pod pushes to the registry as part
+// of the build, whose stage is completed by Build.
+func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) error {
+	return nil
+}
+
+// AuthToken retrieves the auth token for the given image from the docker configuration file.
+func (b *Builder) AuthToken(ctx context.Context, app *builder.AppContext) (string, error) {
+	return builder.AuthTokenFromDockerConfig(app.MainImage)
+}
+
+func (b *Builder) image() string {
+	if b.Image != "" {
+		return b.Image
+	}
+	return DefaultImage
+}
+
+func buildName(app *builder.AppContext) string {
+	return "draft-build-" + strings.ToLower(app.ID)
+}
+
+func labels(app *builder.AppContext) map[string]string {
+	return map[string]string{
+		"heritage": "draft",
+		"appname":  app.Ctx.Env.Name,
+	}
+}
+
+func newSecret(app *builder.AppContext, name string, config []byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels(app),
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{v1.DockerConfigJsonKey: config},
+	}
+}
+
+// newPod returns the pod building the application image for platform, or for the platform of
+// the node it runs on if empty, and tagging it with each of tags. Pods of a build share the
+// secret named after the build.
+func newPod(app *builder.AppContext, name, image string, tags []string, platform string, push bool) *v1.Pod {
+	args := []string{
+		"--dockerfile=" + app.Ctx.Env.Dockerfile,
+		"--context=tar://stdin",
+	}
+	for _, img := range tags {
+		args = append(args, "--destination="+img)
+	}
+	if platform != "" {
+		args = append(args, "--customPlatform="+platform)
+	}
+	if push {
+		// the digest of the pushed image is reported through the termination message.
+		args = append(args, "--digest-file="+terminationLog)
+	} else {
+		args = append(args, "--no-push")
+	}
+	// sort the build args so the pod spec is stable between builds
+	keys := make([]string, 0, len(app.Ctx.Env.ImageBuildArgs))
+	for k := range app.Ctx.Env.ImageBuildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--build-arg", k+"="+app.Ctx.Env.ImageBuildArgs[k])
+	}
+
+	container := v1.Container{
+		Name:      containerName,
+		Image:     image,
+		Args:      args,
+		Stdin:     true,
+		StdinOnce: true,
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels(app),
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+		},
+	}
+	if push {
+		container.VolumeMounts = []v1.VolumeMount{{Name: "docker-config", MountPath: dockerDir}}
+		pod.Spec.Volumes = []v1.Volume{{
+			Name: "docker-config",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: buildName(app),
+					Items:      []v1.KeyToPath{{Key: v1.DockerConfigJsonKey, Path: "config.json"}},
+				},
+			},
+		}}
+	}
+	if platform != "" {
+		// kaniko does not emulate other platforms: the image is built natively on a matching node.
+		if os, arch, _, err := builder.ParsePlatform(platform); err == nil {
+			pod.Spec.NodeSelector = map[string]string{
+				"beta.kubernetes.io/os":   os,
+				"beta.kubernetes.io/arch": arch,
+			}
+		}
+	}
+	pod.Spec.Containers = []v1.Container{container}
+	return pod
+}
+
+// pushedDigest returns the digest of the pushed image written to the termination message
+// of the build container.
+func pushedDigest(pod *v1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if t := status.State.Terminated; t != nil && status.Name == containerName {
+			return strings.TrimSpace(t.Message)
+		}
+	}
+	return ""
+}
+
+// stuckReason describes why a pending build pod will not start without intervention, such
+// as a builder image which cannot be pulled or no node matching the platform of the build.
+// It returns "" while the pod may still start.
+func stuckReason(pod *v1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse && cond.Reason == v1.PodReasonUnschedulable {
+			return cond.Message
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if w := status.State.Waiting; w != nil {
+			switch w.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+				return fmt.Sprintf("%s: %s", w.Reason, w.Message)
+			}
+		}
+	}
+	return ""
+}
+
+// failureReason describes why the build container did not succeed.
+func failureReason(pod *v1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if t := status.State.Terminated; t != nil {
+			if t.Message != "" {
+				return strings.TrimSpace(t.Message)
+			}
+			return fmt.Sprintf("exit code %d", t.ExitCode)
+		}
+		if w := status.State.Waiting; w != nil && w.Reason != "" {
+			return w.Reason
+		}
+	}
+	if pod.Status.Message != "" {
+		return pod.Status.Message
+	}
+	return string(pod.Status.Phase)
+}
@@ -0,0 +1,112 @@
+package incluster
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+)
+
+func TestNewPod(t *testing.T) {
+	app := &builder.AppContext{
+		ID:     "01CA8HX7RZ",
+		Images: []string{"myregistry.io/app:1", "myregistry.io/app:latest"},
+		Ctx: &builder.Context{
+			Env: &manifest.Environment{
+				Name:           "app",
+				Dockerfile:     "Dockerfile.dev",
+				ImageBuildArgs: map[string]string{"B": "2", "A": "1"},
+			},
+		},
+	}
+
+	pod := newPod(app, buildName(app), DefaultImage, app.Images, "", true)
+	if pod.Name != "draft-build-01ca8hx7rz" {
+		t.Errorf("expected pod name %q, got %q", "draft-build-01ca8hx7rz", pod.Name)
+	}
+	expected := []string{
+		"--dockerfile=Dockerfile.dev",
+		"--context=tar://stdin",
+		"--destination=myregistry.io/app:1",
+		"--destination=myregistry.io/app:latest",
+		"--digest-file=/dev/termination-log",
+		"--build-arg", "A=1",
+		"--build-arg", "B=2",
+	}
+	if args := pod.Spec.Containers[0].Args; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].Secret.SecretName != pod.Name {
+		t.Errorf("expected the docker config secret to be mounted, got %v", pod.Spec.Volumes)
+	}
+
+	pod = newPod(app, buildName(app), DefaultImage, app.Images, "linux/arm64", false)
+	args := pod.Spec.Containers[0].Args
+	if args[len(args)-6] != "--customPlatform=linux/arm64" {
+		t.Errorf("expected the build to target linux/arm64, got %v", args)
+	}
+	if args[len(args)-5] != "--no-push" {
+		t.Errorf("expected --no-push when no registry is set, got %v", args)
+	}
+	if len(pod.Spec.Volumes) != 0 {
+		t.Errorf("expected no volumes when no registry is set, got %v", pod.Spec.Volumes)
+	}
+	if arch := pod.Spec.NodeSelector["beta.kubernetes.io/arch"]; arch != "arm64" {
+		t.Errorf("expected the build pod to run on an arm64 node, got %v", pod.Spec.NodeSelector)
+	}
+
+	// the pods of a multi-platform build share the build secret.
+	pod = newPod(app, buildName(app)+"-linux-arm64", DefaultImage, []string{"myregistry.io/app:1-linux-arm64"}, "linux/arm64", true)
+	if pod.Spec.Volumes[0].Secret.SecretName != buildName(app) {
+		t.Errorf("expected the build secret %q to be mounted, got %v", buildName(app), pod.Spec.Volumes)
+	}
+	if args := pod.Spec.Containers[0].Args; args[2] != "--destination=myregistry.io/app:1-linux-arm64" || len(args) != 9 {
+		t.Errorf("expected the platform image to be pushed alone, got %v", args)
+	}
+}
+
+func TestStuckReason(t *testing.T) {
+	var podTests = []struct {
+		status v1.PodStatus
+		stuck  bool
+	}{
+		{v1.PodStatus{Phase: v1.PodPending}, false},
+		{v1.PodStatus{
+			Phase:             v1.PodPending,
+			ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}}},
+		}, false},
+		{v1.PodStatus{
+			Phase:             v1.PodPending,
+			ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}}},
+		}, true},
+		{v1.PodStatus{
+			Phase: v1.PodPending,
+			Conditions: []v1.PodCondition{{
+				Type:    v1.PodScheduled,
+				Status:  v1.ConditionFalse,
+				Reason:  v1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 node(s) didn't match node selector.",
+			}},
+		}, true},
+	}
+
+	for i, tt := range podTests {
+		if reason := stuckReason(&v1.Pod{Status: tt.status}); (reason != "") != tt.stuck {
+			t.Errorf("%d: expected stuck to be %v, got reason %q", i, tt.stuck, reason)
+		}
+	}
+}
+
+func TestPushReportsNothing(t *testing.T) {
+	out := make(chan *builder.Summary, 1)
+	if err := (&Builder{}).Push(context.Background(), &builder.AppContext{ID: "01CA8HX7RZ"}, out); err != nil {
+		t.Fatal(err)
+	}
+	// Build completes the build stage, so a second completion would be recorded twice.
+	if len(out) != 0 {
+		t.Errorf("expected no summaries, got %v", <-out)
+	}
+}
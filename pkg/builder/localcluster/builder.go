@@ -0,0 +1,99 @@
+package localcluster
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Azure/draft/pkg/builder"
+	"golang.org/x/net/context"
+)
+
+// Builder wraps a container builder so that, when no registry is configured, images are
+// loaded into the nodes of a local cluster instead of being pushed to a registry.
+//
+// The wrapped container builder must implement builder.ImageSaver.
+type Builder struct {
+	builder.ContainerBuilder
+	Cluster *Cluster
+}
+
+// Push loads the results of Build into the local cluster, or pushes them to the image
+// repository if a registry is configured.
+func (b *Builder) Push(ctx context.Context, app *builder.AppContext, out chan<- *builder.Summary) (err error) {
+	if app.Ctx.Env.Registry != "" {
+		return b.ContainerBuilder.Push(ctx, app, out)
+	}
+
+	const stageDesc = "Loading Docker Image"
+
+	defer builder.Complete(app.ID, stageDesc, out, &err)
+	summary := builder.Summarize(app.ID, stageDesc, out)
+
+	// notify that particular stage has started.
+	summary("started", builder.SummaryStarted)
+
+	saver, ok := b.ContainerBuilder.(builder.ImageSaver)
+	if !ok {
+		return fmt.Errorf("container builder %q cannot load images into the %s", app.Ctx.Env.ContainerBuilder, b.Cluster)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- b.load(ctx, app, saver, summary)
+	}()
+	return builder.Await(errc, summary)
+}
+
+// load saves the image to a temporary archive and loads it onto the cluster nodes.
+func (b *Builder) load(ctx context.Context, app *builder.AppContext, saver builder.ImageSaver, summary func(string, builder.SummaryStatusCode)) error {
+	f, err := ioutil.TempFile("", "draft-image-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = saver.SaveImage(ctx, app, f, builder.ImageArchiveDocker, summary)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not save image: %v", err)
+	}
+
+	summary(fmt.Sprintf("loading %s into the %s", app.MainImage, b.Cluster), builder.SummaryLogging)
+	w := builder.NewLogWriter(app.Log, summary)
+	defer w.Close()
+	cmd := b.Cluster.loadCommand(ctx, f.Name())
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not load image into the %s: %v", b.Cluster, err)
+	}
+	return nil
+}
+
+// FindImage reports whether the images of app were built before, using the wrapped container
+// builder. Without a registry, a build found this way skips Push, so the images must also be
+// loaded onto the nodes of the cluster already.
+func (b *Builder) FindImage(ctx context.Context, app *builder.AppContext) (bool, error) {
+	finder, ok := b.ContainerBuilder.(builder.ImageFinder)
+	if !ok {
+		return false, nil
+	}
+	exists, err := finder.FindImage(ctx, app)
+	if err != nil || !exists || app.Ctx.Env.Registry != "" {
+		return exists, err
+	}
+	return b.Cluster.hasImages(ctx, app.Images)
+}
+
+// SaveImage writes the images built for app to w using the wrapped container builder.
+func (b *Builder) SaveImage(ctx context.Context, app *builder.AppContext, w io.Writer, format string, summary func(string, builder.SummaryStatusCode)) (string, error) {
+	saver, ok := b.ContainerBuilder.(builder.ImageSaver)
+	if !ok {
+		return "", fmt.Errorf("container builder %q cannot write image archives", app.Ctx.Env.ContainerBuilder)
+	}
+	return saver.SaveImage(ctx, app, w, format, summary)
+}
@@ -0,0 +1,76 @@
+package localcluster
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"golang.org/x/net/context"
+)
+
+// fakeFinder is a container builder which reports whether the image exists locally.
+type fakeFinder struct {
+	builder.ContainerBuilder
+	exists bool
+}
+
+func (f fakeFinder) FindImage(ctx context.Context, app *builder.AppContext) (bool, error) {
+	return f.exists, nil
+}
+
+// fakeOutput fakes the output of the commands listing the nodes and images of a cluster.
+func fakeOutput(outputs map[string]string) func(*exec.Cmd) ([]byte, error) {
+	return func(cmd *exec.Cmd) ([]byte, error) {
+		return []byte(outputs[strings.Join(cmd.Args, " ")]), nil
+	}
+}
+
+func TestFindImage(t *testing.T) {
+	defer func(o func(*exec.Cmd) ([]byte, error)) { output = o }(output)
+	output = fakeOutput(map[string]string{
+		"kind get nodes --name dev":                           "dev-control-plane\ndev-worker\n",
+		"docker exec dev-control-plane crictl images -o json": `{"images": [{"repoTags": ["docker.io/library/app:1234", "docker.io/library/app:latest"]}]}`,
+		"docker exec dev-worker crictl images -o json":        `{"images": [{"repoTags": ["docker.io/library/app:1234"]}]}`,
+		"minikube image ls --profile minikube":                "docker.io/library/app:1234\ndocker.io/library/app:latest\n",
+	})
+
+	var findTests = []struct {
+		name     string
+		cluster  *Cluster
+		exists   bool
+		registry string
+		images   []string
+		expected bool
+	}{
+		{"loaded on every kind node", &Cluster{Type: Kind, Name: "dev"}, true, "", []string{"app:1234"}, true},
+		{"missing from a kind node", &Cluster{Type: Kind, Name: "dev"}, true, "", []string{"app:1234", "app:latest"}, false},
+		{"loaded on minikube", &Cluster{Type: Minikube, Name: "minikube"}, true, "", []string{"app:1234", "app:latest"}, true},
+		{"not built", &Cluster{Type: Minikube, Name: "minikube"}, false, "", []string{"app:1234"}, false},
+		{"pushed to a registry", &Cluster{Type: Kind, Name: "dev"}, true, "example.azurecr.io", []string{"example.azurecr.io/app:5678"}, true},
+	}
+
+	for _, tt := range findTests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{ContainerBuilder: fakeFinder{exists: tt.exists}, Cluster: tt.cluster}
+			app := &builder.AppContext{
+				Images: tt.images,
+				Ctx:    &builder.Context{Env: &manifest.Environment{Registry: tt.registry}},
+			}
+			// the build is only skipped if the wrapper is found to be an image finder.
+			var cb builder.ContainerBuilder = b
+			finder, ok := cb.(builder.ImageFinder)
+			if !ok {
+				t.Fatal("expected the local cluster builder to find images")
+			}
+			exists, err := finder.FindImage(context.Background(), app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.expected {
+				t.Errorf("expected %v but got %v", tt.expected, exists)
+			}
+		})
+	}
+}
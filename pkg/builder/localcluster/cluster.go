@@ -0,0 +1,130 @@
+package localcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"golang.org/x/net/context"
+)
+
+const (
+	// Kind is a cluster created by kind (Kubernetes in Docker).
+	Kind = "kind"
+	// Minikube is a cluster created by minikube.
+	Minikube = "minikube"
+
+	kindContextPrefix = "kind-"
+)
+
+// output runs cmd and returns its standard output. Tests replace it to fake the commands.
+var output = func(cmd *exec.Cmd) ([]byte, error) {
+	return cmd.Output()
+}
+
+// Cluster is a local Kubernetes cluster which can load images straight into the
+// container runtime of its nodes.
+type Cluster struct {
+	// Type is either Kind or Minikube.
+	Type string
+	// Name is the name of the kind cluster or minikube profile.
+	Name string
+}
+
+// Detect returns the local cluster the kubeconfig context with the given name
+// belongs to, or nil if it is not a kind or minikube cluster.
+func Detect(context string) *Cluster {
+	switch {
+	case strings.HasPrefix(context, kindContextPrefix) && len(context) > len(kindContextPrefix):
+		return &Cluster{Type: Kind, Name: strings.TrimPrefix(context, kindContextPrefix)}
+	case context == Minikube:
+		return &Cluster{Type: Minikube, Name: context}
+	}
+	return nil
+}
+
+// String returns a description of the cluster such as "kind cluster foo".
+func (c *Cluster) String() string {
+	return c.Type + " cluster " + c.Name
+}
+
+// loadCommand returns the command loading the image archive at path onto the nodes of the cluster.
+func (c *Cluster) loadCommand(ctx context.Context, path string) *exec.Cmd {
+	if c.Type == Minikube {
+		return exec.CommandContext(ctx, "minikube", "image", "load", path, "--profile", c.Name)
+	}
+	return exec.CommandContext(ctx, "kind", "load", "image-archive", path, "--name", c.Name)
+}
+
+// hasImages reports whether every one of images is present on every node of the cluster.
+func (c *Cluster) hasImages(ctx context.Context, images []string) (bool, error) {
+	var nodes [][]string
+	if c.Type == Minikube {
+		out, err := output(exec.CommandContext(ctx, "minikube", "image", "ls", "--profile", c.Name))
+		if err != nil {
+			return false, fmt.Errorf("could not list the images of the %s: %v", c, err)
+		}
+		nodes = append(nodes, strings.Fields(string(out)))
+	} else {
+		out, err := output(exec.CommandContext(ctx, "kind", "get", "nodes", "--name", c.Name))
+		if err != nil {
+			return false, fmt.Errorf("could not list the nodes of the %s: %v", c, err)
+		}
+		for _, node := range strings.Fields(string(out)) {
+			tags, err := c.nodeImages(ctx, node)
+			if err != nil {
+				return false, err
+			}
+			nodes = append(nodes, tags)
+		}
+	}
+	if len(nodes) == 0 {
+		return false, nil
+	}
+
+	for _, tags := range nodes {
+		present := make(map[string]bool)
+		for _, tag := range tags {
+			present[normalize(tag)] = true
+		}
+		for _, image := range images {
+			if !present[normalize(image)] {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// nodeImages returns the tags of the images in the container runtime of a kind node.
+func (c *Cluster) nodeImages(ctx context.Context, node string) ([]string, error) {
+	out, err := output(exec.CommandContext(ctx, "docker", "exec", node, "crictl", "images", "-o", "json"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list the images of node %s: %v", node, err)
+	}
+	var list struct {
+		Images []struct {
+			RepoTags []string `json:"repoTags"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("could not list the images of node %s: %v", node, err)
+	}
+	var tags []string
+	for _, image := range list.Images {
+		tags = append(tags, image.RepoTags...)
+	}
+	return tags, nil
+}
+
+// normalize returns the fully qualified form of an image reference, such as
+// docker.io/library/app:latest for app, as listed by the container runtime of the nodes.
+func normalize(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	return reference.TagNameOnly(named).String()
+}
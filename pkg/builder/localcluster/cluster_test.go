@@ -0,0 +1,49 @@
+package localcluster
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestDetect(t *testing.T) {
+	var detectTests = []struct {
+		context string
+		cluster *Cluster
+	}{
+		{"kind-kind", &Cluster{Type: Kind, Name: "kind"}},
+		{"kind-dev", &Cluster{Type: Kind, Name: "dev"}},
+		{"minikube", &Cluster{Type: Minikube, Name: "minikube"}},
+		{"kind-", nil},
+		{"docker-for-desktop", nil},
+		{"", nil},
+	}
+
+	for _, tt := range detectTests {
+		t.Run(tt.context, func(t *testing.T) {
+			if actual := Detect(tt.context); !reflect.DeepEqual(actual, tt.cluster) {
+				t.Errorf("expected %v but got %v", tt.cluster, actual)
+			}
+		})
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	var loadTests = []struct {
+		cluster *Cluster
+		args    []string
+	}{
+		{&Cluster{Type: Kind, Name: "dev"}, []string{"kind", "load", "image-archive", "image.tar", "--name", "dev"}},
+		{&Cluster{Type: Minikube, Name: "minikube"}, []string{"minikube", "image", "load", "image.tar", "--profile", "minikube"}},
+	}
+
+	for _, tt := range loadTests {
+		t.Run(tt.cluster.String(), func(t *testing.T) {
+			cmd := tt.cluster.loadCommand(context.Background(), "image.tar")
+			if !reflect.DeepEqual(cmd.Args, tt.args) {
+				t.Errorf("expected %v but got %v", tt.args, cmd.Args)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logWriter copies build output to the build log and reports every line of it as a
+// SummaryLogging summary so that it can be followed while the build runs.
+type logWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	summary func(string, SummaryStatusCode)
+	buf     bytes.Buffer
+}
+
+// NewLogWriter returns a writer which copies everything written to it to w, reporting
+// each complete line through summary as a SummaryLogging summary. Close reports any
+// remaining partial line; it does not close w.
+//
+// The returned writer is safe for concurrent use.
+func NewLogWriter(w io.Writer, summary func(string, SummaryStatusCode)) io.WriteCloser {
+	return &logWriter{w: w, summary: summary}
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	n, err := lw.w.Write(p)
+	lw.buf.Write(p[:n])
+	for {
+		i := bytes.IndexByte(lw.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		lw.report(string(lw.buf.Next(i + 1)))
+	}
+	return n, err
+}
+
+func (lw *logWriter) Close() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.report(lw.buf.String())
+	lw.buf.Reset()
+	return nil
+}
+
+// report sends line as a summary. Progress output redraws a line using carriage
+// returns, in which case only its final state is reported.
+func (lw *logWriter) report(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	if strings.TrimSpace(line) != "" {
+		lw.summary(line, SummaryLogging)
+	}
+}
+
+// Await reports that a stage is ongoing through summary every second until errc
+// yields the stage's result, which it returns.
+func Await(errc <-chan error, summary func(string, SummaryStatusCode)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-ticker.C:
+			summary("ongoing", SummaryOngoing)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestLogWriter(t *testing.T) {
+	var (
+		log   bytes.Buffer
+		lines []string
+	)
+	w := NewLogWriter(&log, func(text string, code SummaryStatusCode) {
+		if code != SummaryLogging {
+			t.Errorf("expected %s, got %s", SummaryStatusCodeName[int(SummaryLogging)], SummaryStatusCodeName[int(code)])
+		}
+		lines = append(lines, text)
+	})
+	input := "Step 1/2 : FROM alpine\n\nDownloading 10%\rDownloading 100%\nStep 2/2"
+	for _, chunk := range []string{input[:10], input[10:30], input[30:]} {
+		fmt.Fprint(w, chunk)
+	}
+	w.Close()
+
+	if log.String() != input {
+		t.Errorf("expected the build log to contain %q, got %q", input, log.String())
+	}
+	expected := []string{"Step 1/2 : FROM alpine", "Downloading 100%", "Step 2/2"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected lines %q, got %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("expected line %d to be %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
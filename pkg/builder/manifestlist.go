@@ -0,0 +1,248 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+const (
+	mediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// challengeParam matches the parameters of a WWW-Authenticate challenge.
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// manifestList is a docker manifest list, referencing the image of each platform.
+type manifestList struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType"`
+	Manifests     []manifestDescriptor `json:"manifests"`
+}
+
+type manifestDescriptor struct {
+	MediaType string       `json:"mediaType"`
+	Size      int          `json:"size"`
+	Digest    string       `json:"digest"`
+	Platform  platformSpec `json:"platform"`
+}
+
+type platformSpec struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// PushManifestList pushes a manifest list under each of the tags of images, referencing
+// the images of a multi-platform build which were pushed under the tags returned by
+// PlatformImage. It returns the digest of the manifest list.
+//
+// authToken holds the credentials of the registry, as returned by the container builders.
+func PushManifestList(ctx context.Context, authToken string, images, platforms []string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(images[0])
+	if err != nil {
+		return "", err
+	}
+	client := newRegistryClient(reference.Domain(named), reference.Path(named), authToken)
+
+	list := manifestList{SchemaVersion: 2, MediaType: mediaTypeManifestList}
+	for _, platform := range platforms {
+		os, arch, variant, err := ParsePlatform(platform)
+		if err != nil {
+			return "", err
+		}
+		_, tag := splitImage(PlatformImage(images[0], platform))
+		desc, err := client.manifest(ctx, tag)
+		if err != nil {
+			return "", fmt.Errorf("could not get the manifest of the %s image: %v", platform, err)
+		}
+		desc.Platform = platformSpec{Architecture: arch, OS: os, Variant: variant}
+		list.Manifests = append(list.Manifests, *desc)
+	}
+	// the order of the platforms does not change the manifest list, nor its digest.
+	sort.Slice(list.Manifests, func(i, j int) bool {
+		return list.Manifests[i].Digest < list.Manifests[j].Digest
+	})
+	data, err := json.MarshalIndent(list, "", "   ")
+	if err != nil {
+		return "", err
+	}
+	for _, image := range images {
+		_, tag := splitImage(image)
+		if err := client.putManifestList(ctx, tag, data); err != nil {
+			return "", fmt.Errorf("could not push the manifest list of %s: %v", image, err)
+		}
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// registryClient talks to the manifests endpoint of a repository of a docker registry.
+type registryClient struct {
+	client     *http.Client
+	endpoint   string
+	repository string
+	auth       types.AuthConfig
+	// authorization is the Authorization header answering the latest challenge of the registry.
+	authorization string
+}
+
+func newRegistryClient(domain, repository, authToken string) *registryClient {
+	scheme := "https"
+	// like docker, registries on the local host are trusted over plain HTTP.
+	if strings.HasPrefix(domain, "localhost") || strings.HasPrefix(domain, "127.0.0.1") {
+		scheme = "http"
+	}
+	if domain == "docker.io" {
+		domain = "registry-1.docker.io"
+	}
+	c := &registryClient{
+		client:     http.DefaultClient,
+		endpoint:   scheme + "://" + domain,
+		repository: repository,
+	}
+	// the auth token is base64 encoded JSON, URL-safe when encoded by the docker CLI.
+	for _, encoding := range []*base64.Encoding{base64.URLEncoding, base64.StdEncoding} {
+		if data, err := encoding.DecodeString(authToken); err == nil && json.Unmarshal(data, &c.auth) == nil {
+			break
+		}
+	}
+	return c
+}
+
+// manifest returns the descriptor of the image manifest tagged tag.
+func (c *registryClient) manifest(ctx context.Context, tag string) (*manifestDescriptor, error) {
+	resp, err := c.do(ctx, "GET", c.manifestURL(tag), func(req *http.Request) {
+		req.Header.Set("Accept", mediaTypeManifest+", "+mediaTypeOCIManifest)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	if mediaType != mediaTypeManifest && mediaType != mediaTypeOCIManifest {
+		return nil, fmt.Errorf("unexpected manifest type %q", mediaType)
+	}
+	return &manifestDescriptor{
+		MediaType: mediaType,
+		Size:      len(data),
+		Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+	}, nil
+}
+
+// putManifestList tags the manifest list in data as tag.
+func (c *registryClient) putManifestList(ctx context.Context, tag string, data []byte) error {
+	resp, err := c.do(ctx, "PUT", c.manifestURL(tag), func(req *http.Request) {
+		req.Header.Set("Content-Type", mediaTypeManifestList)
+	}, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *registryClient) manifestURL(tag string) string {
+	return fmt.Sprintf("%s/v2/%s/manifests/%s", c.endpoint, c.repository, tag)
+}
+
+// do sends a request to the registry, answering its authentication challenge if any.
+func (c *registryClient) do(ctx context.Context, method, u string, prepare func(*http.Request), body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		prepare(req)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		return c.client.Do(req.WithContext(ctx))
+	}
+	resp, err := send()
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && c.authorization == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		resp, err = send()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// authorize answers a basic or bearer token authentication challenge of the registry with
+// the credentials of the client.
+func (c *registryClient) authorize(ctx context.Context, challenge string) error {
+	params := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	switch {
+	case strings.HasPrefix(challenge, "Basic"):
+		req := &http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+		c.authorization = req.Header.Get("Authorization")
+		return nil
+	case strings.HasPrefix(challenge, "Bearer"):
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	query := url.Values{"scope": {fmt.Sprintf("repository:%s:pull,push", c.repository)}}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	req, err := http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.auth.Username != "" {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("could not get a registry token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get a registry token: %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("could not get a registry token: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	c.authorization = "Bearer " + token.Token
+	return nil
+}
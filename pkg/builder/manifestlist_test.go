@@ -0,0 +1,93 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestPushManifestList(t *testing.T) {
+	manifests := map[string]string{
+		"1-linux-amd64": `{"schemaVersion": 2, "config": "amd64"}`,
+		"1-linux-arm64": `{"schemaVersion": 2, "config": "arm64"}`,
+	}
+	pushed := make(map[string][]byte)
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if scope := r.URL.Query().Get("scope"); scope != "repository:app:pull,push" {
+			t.Errorf("expected a token for the app repository, got scope %q", scope)
+		}
+		fmt.Fprint(w, `{"token": "t0ken"}`)
+	}))
+	defer tokens.Close()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="registry"`, tokens.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tag := strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")
+		switch r.Method {
+		case "GET":
+			manifest, ok := manifests[tag]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", mediaTypeManifest)
+			fmt.Fprint(w, manifest)
+		case "PUT":
+			if ct := r.Header.Get("Content-Type"); ct != mediaTypeManifestList {
+				t.Errorf("expected a manifest list, got %q", ct)
+			}
+			pushed[tag], _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer registry.Close()
+
+	repository := strings.TrimPrefix(registry.URL, "http://") + "/app"
+	authToken := base64.URLEncoding.EncodeToString([]byte(`{"username": "user", "password": "secret"}`))
+	images := []string{repository + ":1", repository + ":latest"}
+
+	digest, err := PushManifestList(context.Background(), authToken, images, []string{"linux/arm64", "linux/amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pushed) != 2 || string(pushed["1"]) != string(pushed["latest"]) {
+		t.Fatalf("expected the manifest list to be pushed under both tags, got %v", pushed)
+	}
+	if expected := fmt.Sprintf("sha256:%x", sha256.Sum256(pushed["1"])); digest != expected {
+		t.Errorf("expected digest %s, got %s", expected, digest)
+	}
+
+	var list manifestList
+	if err := json.Unmarshal(pushed["1"], &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Manifests) != 2 {
+		t.Fatalf("expected 2 manifests, got %v", list.Manifests)
+	}
+	for _, desc := range list.Manifests {
+		manifest := manifests["1-"+desc.Platform.OS+"-"+desc.Platform.Architecture]
+		if expected := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest))); desc.Digest != expected {
+			t.Errorf("%s: expected digest %s, got %s", desc.Platform.Architecture, expected, desc.Digest)
+		}
+		if desc.Size != len(manifest) {
+			t.Errorf("%s: expected size %d, got %d", desc.Platform.Architecture, len(manifest), desc.Size)
+		}
+	}
+}
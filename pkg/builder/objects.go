@@ -0,0 +1,300 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/draft/pack"
+	"github.com/Azure/draft/pkg/local"
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// documentSeparator splits YAML documents.
+var documentSeparator = regexp.MustCompile(`(?:^|\s*\n)---\s*`)
+
+// workloadKinds are the kinds of objects whose pod template is labelled and has the images
+// built by draft substituted into its containers.
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"Job":         true,
+}
+
+// ObjectApplier applies the objects of applications deployed from plain manifests or a
+// kustomization rather than a chart.
+type ObjectApplier interface {
+	// Apply creates or updates objs with server-side apply. Namespaced objects without a
+	// namespace are applied to namespace.
+	Apply(namespace string, objs []*unstructured.Unstructured) error
+	// Delete deletes the objects in namespace and the cluster-scoped objects matching the label
+	// selector, except for those owned by other objects, which are garbage collected along with
+	// their owners.
+	Delete(namespace, selector string) error
+}
+
+// DeploysObjects reports whether env deploys plain manifests or a kustomization instead of
+// a chart.
+func DeploysObjects(env *manifest.Environment) bool {
+	return env.Manifests != "" || env.Kustomize != ""
+}
+
+// deployDir returns the directory, relative to the application directory, holding what is
+// deployed for env: its kustomization, its manifests or its chart.
+func deployDir(env *manifest.Environment) string {
+	switch {
+	case env.Kustomize != "":
+		return env.Kustomize
+	case env.Manifests != "":
+		return env.Manifests
+	case env.Chart != "":
+		return env.Chart
+	}
+	return pack.ChartsDir
+}
+
+// loadObjects reads the objects deployed for the environment: the output of
+// `kubectl kustomize` for a kustomization, or else the YAML and JSON files of the
+// manifests directory in lexical order.
+func loadObjects(ctx *Context) ([]*unstructured.Unstructured, error) {
+	dir := filepath.Join(ctx.AppDir, deployDir(ctx.Env))
+	if ctx.Env.Kustomize != "" {
+		out, err := exec.Command("kubectl", "kustomize", dir).Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				return nil, fmt.Errorf("could not build kustomization %s: %s", dir, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return nil, fmt.Errorf("could not build kustomization %s: %v", dir, err)
+		}
+		objs, err := parseObjects(ctx.Env.Kustomize, string(out))
+		if err != nil {
+			return nil, err
+		}
+		return objs, checkNamespaces(objs, ctx.Env.Namespace)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, file := range files {
+		switch filepath.Ext(file.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseObjects(file.Name(), string(data))
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, parsed...)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no Kubernetes objects found in %s", dir)
+	}
+	return objs, checkNamespaces(objs, ctx.Env.Namespace)
+}
+
+// checkNamespaces rejects objects set to another namespace than the namespace of the
+// environment, whose objects are the only ones cleaned up by later builds and draft delete.
+func checkNamespaces(objs []*unstructured.Unstructured, namespace string) error {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	for _, obj := range objs {
+		if ns := obj.GetNamespace(); ns != "" && ns != namespace {
+			return fmt.Errorf("%s %s is in namespace %s, but only objects in namespace %s can be deployed", obj.GetKind(), obj.GetName(), ns, namespace)
+		}
+	}
+	return nil
+}
+
+// parseObjects parses the YAML or JSON documents in data, read from source.
+func parseObjects(source, data string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	for _, doc := range documentSeparator.Split(data, -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		js, err := yaml.YAMLToJSON([]byte(doc))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", source, err)
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(js, &obj); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", source, err)
+		}
+		// documents holding nothing but comments
+		if obj == nil {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("could not parse %s: objects must have an apiVersion, a kind and a name", source)
+		}
+		objs = append(objs, u)
+	}
+	return objs, nil
+}
+
+// prepareObjects returns copies of objs labelled with labels. Workloads also have their pod
+// template labelled, and the image of each container whose repository is a key of images
+// replaced with the corresponding reference.
+//
+// The pod template of a Job cannot be updated, so Jobs are named after the build ID label:
+// each build creates a Job of its own, and the Job of the previous build is deleted with the
+// other objects the build no longer deploys.
+func prepareObjects(objs []*unstructured.Unstructured, images, labels map[string]string) ([]*unstructured.Unstructured, error) {
+	prepared := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		obj = obj.DeepCopy()
+		obj.SetLabels(mergeLabels(obj.GetLabels(), labels))
+		if obj.GetKind() == "Job" {
+			obj.SetName(buildObjectName(obj.GetName(), labels[local.BuildIDKey]))
+		}
+		if workloadKinds[obj.GetKind()] {
+			if err := prepareTemplate(obj.Object, images, labels); err != nil {
+				return nil, fmt.Errorf("%s %s: %v", obj.GetKind(), obj.GetName(), err)
+			}
+		}
+		prepared = append(prepared, obj)
+	}
+	return prepared, nil
+}
+
+// prepareTemplate labels the pod template of a workload and substitutes the images of its
+// containers.
+func prepareTemplate(obj map[string]interface{}, images, labels map[string]string) error {
+	template, ok := field(obj, "spec", "template")
+	if !ok {
+		return errors.New("no pod template found in spec.template")
+	}
+	metadata, _ := field(template, "metadata")
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+		template["metadata"] = metadata
+	}
+	existing := make(map[string]string)
+	if l, ok := metadata["labels"].(map[string]interface{}); ok {
+		for k, v := range l {
+			existing[k], _ = v.(string)
+		}
+	}
+	merged := make(map[string]interface{})
+	for k, v := range mergeLabels(existing, labels) {
+		merged[k] = v
+	}
+	metadata["labels"] = merged
+
+	spec, ok := field(template, "spec")
+	if !ok {
+		return errors.New("no pod spec found in spec.template.spec")
+	}
+	for _, key := range []string{"initContainers", "containers"} {
+		containers, _ := spec[key].([]interface{})
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			image, _ := container["image"].(string)
+			if ref, ok := images[imageRepository(image)]; ok {
+				container["image"] = ref
+			}
+		}
+	}
+	return nil
+}
+
+// buildObjectName suffixes name with buildID, shortening name so that the result remains a
+// valid label value, as the name of a Job is used to label its pods.
+func buildObjectName(name, buildID string) string {
+	suffix := "-" + strings.ToLower(buildID)
+	if max := validation.LabelValueMaxLength - len(suffix); len(name) > max {
+		name = strings.TrimRight(name[:max], "-.")
+	}
+	return name + suffix
+}
+
+// imageReferences returns the references of the images built for app, keyed by the
+// repositories they replace in the manifests: the repository of each image, its last path
+// element, and the name of the service it was built for.
+func imageReferences(app *AppContext) map[string]string {
+	images := make(map[string]string)
+	add := func(image, digest string, names ...string) {
+		repository, _ := splitImage(image)
+		ref := image
+		if digest != "" {
+			// tags can be overwritten, so pin the objects to the digest of the pushed image.
+			ref = repository + "@" + digest
+		}
+		for _, name := range append(names, repository, path.Base(repository)) {
+			images[name] = ref
+		}
+	}
+	if len(app.Services) == 0 {
+		add(app.MainImage, app.Digest)
+	}
+	for _, svc := range app.Services {
+		add(svc.MainImage, svc.Digest, svc.Ctx.Service)
+	}
+	return images
+}
+
+// imageRepository returns the repository of an image reference, without its tag or digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	repository, _ := splitImage(image)
+	return repository
+}
+
+// objectLabels returns the labels tracking the objects deployed for app.
+func objectLabels(app *AppContext) map[string]string {
+	return map[string]string{
+		local.DraftLabelKey: app.Ctx.Env.Name,
+		local.BuildIDKey:    app.ID,
+	}
+}
+
+func mergeLabels(labels, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(labels)+len(extra))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+// field returns the nested map found by following keys from obj.
+func field(obj map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		obj = next
+	}
+	return obj, true
+}
@@ -0,0 +1,206 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDeployDir(t *testing.T) {
+	var dirTests = []struct {
+		env      *manifest.Environment
+		expected string
+	}{
+		{&manifest.Environment{}, "charts"},
+		{&manifest.Environment{Chart: "charts/app"}, "charts/app"},
+		{&manifest.Environment{Chart: "charts/app", Manifests: "k8s"}, "k8s"},
+		{&manifest.Environment{Manifests: "k8s", Kustomize: "overlays/dev"}, "overlays/dev"},
+	}
+
+	for _, tt := range dirTests {
+		if actual := deployDir(tt.env); actual != tt.expected {
+			t.Errorf("expected %q but got %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestLoadObjects(t *testing.T) {
+	ctx := &Context{
+		AppDir: filepath.Join("testdata", "manifests"),
+		Env:    &manifest.Environment{Name: "app", Manifests: "k8s"},
+	}
+	objs, err := loadObjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetKind())
+	}
+	if expected := []string{"Deployment", "Service", "ConfigMap"}; !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("expected objects %v, got %v", expected, kinds)
+	}
+
+	ctx.Env.Manifests = "missing"
+	if _, err := loadObjects(ctx); err == nil {
+		t.Error("expected an error loading a missing manifests directory")
+	}
+}
+
+func TestParseObjects(t *testing.T) {
+	if _, err := parseObjects("invalid.yaml", "kind: [Deployment"); err == nil {
+		t.Error("expected an error parsing invalid YAML")
+	}
+	if _, err := parseObjects("nameless.yaml", "apiVersion: v1\nkind: ConfigMap\n"); err == nil {
+		t.Error("expected an error parsing an object without a name")
+	}
+	objs, err := parseObjects("app.json", `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "app"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetName() != "app" {
+		t.Errorf("expected service app, got %v", objs)
+	}
+}
+
+func TestCheckNamespaces(t *testing.T) {
+	objs, err := parseObjects("app.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: default\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkNamespaces(objs, ""); err != nil {
+		t.Errorf("expected objects in the default namespace to be accepted, got %v", err)
+	}
+	if err := checkNamespaces(objs, "dev"); err == nil {
+		t.Error("expected objects in another namespace to be rejected")
+	}
+}
+
+func TestPrepareObjects(t *testing.T) {
+	ctx := &Context{
+		AppDir: filepath.Join("testdata", "manifests"),
+		Env:    &manifest.Environment{Name: "app", Manifests: "k8s"},
+	}
+	objs, err := loadObjects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &AppContext{
+		ID:        "01CBKJ4AE6GB4Y",
+		Ctx:       ctx,
+		MainImage: "example.azurecr.io/app:1234",
+		Digest:    "sha256:abcd",
+	}
+	prepared, err := prepareObjects(objs, imageReferences(app), objectLabels(app))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := prepared[0]
+	expectedLabels := map[string]string{"tier": "web", "draft": "app", "buildID": "01CBKJ4AE6GB4Y"}
+	if labels := deployment.GetLabels(); !reflect.DeepEqual(labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, labels)
+	}
+	templateLabels, _, _ := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "labels")
+	if expected := map[string]string{"app": "app", "draft": "app", "buildID": "01CBKJ4AE6GB4Y"}; !reflect.DeepEqual(templateLabels, expected) {
+		t.Errorf("expected pod template labels %v, got %v", expected, templateLabels)
+	}
+
+	spec, _ := field(deployment.Object, "spec", "template", "spec")
+	var images []string
+	for _, key := range []string{"initContainers", "containers"} {
+		for _, c := range spec[key].([]interface{}) {
+			images = append(images, c.(map[string]interface{})["image"].(string))
+		}
+	}
+	expectedImages := []string{
+		"example.azurecr.io/app@sha256:abcd",
+		"example.azurecr.io/app@sha256:abcd",
+		"envoyproxy/envoy:v1.6.0",
+	}
+	if !reflect.DeepEqual(images, expectedImages) {
+		t.Errorf("expected images %v, got %v", expectedImages, images)
+	}
+
+	if labels := prepared[1].GetLabels(); !reflect.DeepEqual(labels, map[string]string{"draft": "app", "buildID": "01CBKJ4AE6GB4Y"}) {
+		t.Errorf("expected the service to be labelled, got %v", labels)
+	}
+	// the loaded objects are left untouched for later builds.
+	if labels := objs[0].GetLabels(); !reflect.DeepEqual(labels, map[string]string{"tier": "web"}) {
+		t.Errorf("expected the loaded objects not to be modified, got labels %v", labels)
+	}
+}
+
+func TestPrepareObjectsRedeployJob(t *testing.T) {
+	objs, err := parseObjects("job.yaml", `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: app
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy := func(id, digest string) *unstructured.Unstructured {
+		app := &AppContext{
+			ID:        id,
+			Ctx:       &Context{Env: &manifest.Environment{Name: "app"}},
+			MainImage: "example.azurecr.io/app:" + id,
+			Digest:    digest,
+		}
+		prepared, err := prepareObjects(objs, imageReferences(app), objectLabels(app))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prepared[0]
+	}
+
+	// the pod template of a Job is immutable, so a redeploy must create a new Job rather
+	// than update the Job of the previous build.
+	first, second := deploy("01CBKJ4AE6GB4Y", "sha256:abcd"), deploy("01CBKJ7Q3RZX5N", "sha256:ef01")
+	if first.GetName() != "migrate-01cbkj4ae6gb4y" {
+		t.Errorf("expected job %q, got %q", "migrate-01cbkj4ae6gb4y", first.GetName())
+	}
+	if first.GetName() == second.GetName() {
+		t.Errorf("expected each build to create a job of its own, got %q twice", first.GetName())
+	}
+	if objs[0].GetName() != "migrate" {
+		t.Errorf("expected the loaded job not to be renamed, got %q", objs[0].GetName())
+	}
+
+	long := strings.Repeat("a", 60)
+	if name := buildObjectName(long, "01CBKJ4AE6GB4Y"); len(name) != 63 || !strings.HasSuffix(name, "-01cbkj4ae6gb4y") {
+		t.Errorf("expected a 63 character name ending with the build ID, got %q", name)
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	app := &AppContext{
+		MainImage: "example.azurecr.io/app-api:1234",
+		Services: []*AppContext{
+			{Ctx: &Context{Service: "api"}, MainImage: "example.azurecr.io/app-api:1234", Digest: "sha256:abcd"},
+			{Ctx: &Context{Service: "web"}, MainImage: "example.azurecr.io/app-web:5678"},
+		},
+	}
+	expected := map[string]string{
+		"api":                        "example.azurecr.io/app-api@sha256:abcd",
+		"app-api":                    "example.azurecr.io/app-api@sha256:abcd",
+		"example.azurecr.io/app-api": "example.azurecr.io/app-api@sha256:abcd",
+		"web":                        "example.azurecr.io/app-web:5678",
+		"app-web":                    "example.azurecr.io/app-web:5678",
+		"example.azurecr.io/app-web": "example.azurecr.io/app-web:5678",
+	}
+	if actual := imageReferences(app); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
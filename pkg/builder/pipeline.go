@@ -0,0 +1,212 @@
+package builder
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// stage is a node of the stage graph run by Up.
+type stage struct {
+	// name identifies the stage to the stages depending on it.
+	name string
+	// deps are the names of the stages which must succeed before the stage runs.
+	deps []string
+	run  func(ctx context.Context) error
+}
+
+// runStages runs a stage graph. Every stage starts as soon as all of its dependencies have
+// succeeded, so independent stages overlap.
+//
+// The first error cancels the context of the stages still running, and no further stages
+// are started once a stage failed or ctx was cancelled. runStages returns the first error,
+// or the error of ctx if it was cancelled before every stage could run.
+func runStages(ctx context.Context, stages []*stage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stagesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		stage *stage
+		err   error
+	}
+	results := make(chan result, len(stages))
+	unmet := make(map[*stage]int)
+	dependents := make(map[string][]*stage)
+	for _, s := range stages {
+		unmet[s] = len(s.deps)
+		for _, dep := range s.deps {
+			dependents[dep] = append(dependents[dep], s)
+		}
+	}
+
+	var running, finished int
+	start := func(s *stage) {
+		running++
+		go func() {
+			results <- result{s, s.run(stagesCtx)}
+		}()
+	}
+	for _, s := range stages {
+		if len(s.deps) == 0 {
+			start(s)
+		}
+	}
+
+	var err error
+	for running > 0 {
+		r := <-results
+		running--
+		finished++
+		if r.err != nil {
+			if err == nil {
+				err = r.err
+				cancel()
+			}
+			continue
+		}
+		if err != nil || ctx.Err() != nil {
+			continue
+		}
+		for _, s := range dependents[r.stage.name] {
+			if unmet[s]--; unmet[s] == 0 {
+				start(s)
+			}
+		}
+	}
+	switch {
+	case err != nil:
+		return err
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case finished < len(stages):
+		return fmt.Errorf("%d stages have unresolvable dependencies", len(stages)-finished)
+	}
+	return nil
+}
+
+// stages returns the stage graph of a build of app.
+//
+// The images of app, or of each of its services, are built independently of each other.
+// Once an image is built it is saved and pushed at the same time, and the application is
+// released once every image has been saved and pushed. Chart-only builds reuse the image
+// of the latest build before releasing.
+//
+// done must be called once the stages have run.
+func (b *Builder) stages(app *AppContext, out chan<- *Summary) (stages []*stage, done func()) {
+	release := &stage{
+		name: "release",
+		run: func(ctx context.Context) error {
+			app.Obj.ImageDigest = app.Digest
+			for _, svc := range app.Services {
+				if svc.Digest == "" {
+					continue
+				}
+				if app.Obj.ImageDigests == nil {
+					app.Obj.ImageDigests = make(map[string]string)
+				}
+				app.Obj.ImageDigests[svc.MainImage] = svc.Digest
+			}
+			return logError("releasing", b.release(ctx, app, out))
+		},
+	}
+	if app.Ctx.ChartOnly {
+		release.deps = []string{"reuse"}
+		return []*stage{
+			{
+				name: "reuse",
+				run: func(ctx context.Context) error {
+					return logError("reusing image", b.reuseImage(ctx, app, out))
+				},
+			},
+			release,
+		}, func() {}
+	}
+
+	var closers []func()
+	addImage := func(prefix string, app *AppContext, out chan<- *Summary) {
+		build, save, push := b.imageStages(prefix, app, out)
+		if app.Ctx.Service != "" {
+			serviceErrors(app, build, save, push)
+		}
+		stages = append(stages, build, save, push)
+		release.deps = append(release.deps, save.name, push.name)
+	}
+	if len(app.Services) == 0 {
+		addImage("", app, out)
+	}
+	for _, svc := range app.Services {
+		svcOut, closeOut := labelService(svc, out)
+		addImage(svc.Ctx.Service+"/", svc, svcOut)
+		closers = append(closers, closeOut)
+	}
+	return append(stages, release), func() {
+		for _, closeOut := range closers {
+			closeOut()
+		}
+	}
+}
+
+// imageStages returns the stages building, saving and pushing the image of app, their names
+// prefixed with prefix. Saving and pushing are skipped if the image exists already.
+func (b *Builder) imageStages(prefix string, app *AppContext, out chan<- *Summary) (build, save, push *stage) {
+	// cached is only set by the build stage, which the other stages depend on.
+	var cached bool
+	build = &stage{
+		name: prefix + "build",
+		run: func(ctx context.Context) error {
+			if cached = b.imageExists(ctx, app, out); cached {
+				return nil
+			}
+			return logError("building", b.ContainerBuilder.Build(ctx, app, out))
+		},
+	}
+	save = &stage{
+		name: prefix + "save",
+		deps: []string{build.name},
+		run: func(ctx context.Context) error {
+			if cached {
+				return nil
+			}
+			return logError("saving image", b.saveImage(ctx, app, out))
+		},
+	}
+	push = &stage{
+		name: prefix + "push",
+		deps: []string{build.name},
+		run: func(ctx context.Context) error {
+			if cached {
+				return nil
+			}
+			return logError("pushing", b.ContainerBuilder.Push(ctx, app, out))
+		},
+	}
+	return build, save, push
+}
+
+// logError writes err to the build log, if any, and returns it.
+func logError(action string, err error) error {
+	if err != nil {
+		log.Printf("error while %s: %v\n", action, err)
+	}
+	return err
+}
+
+// completed reports whether code marks the end of a stage.
+func completed(code SummaryStatusCode) bool {
+	switch code {
+	case SummarySuccess, SummaryFailure, SummaryCached, SummaryCancelled:
+		return true
+	}
+	return false
+}
+
+// cancelled reports whether err stems from the cancellation of a build. Clients such as the
+// docker client wrap the error of the cancelled context, so its message is matched too.
+func cancelled(err error) bool {
+	return err == context.Canceled || strings.Contains(err.Error(), context.Canceled.Error())
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// stageRecorder records the order in which stages ran.
+type stageRecorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *stageRecorder) stage(name string, err error, deps ...string) *stage {
+	return &stage{
+		name: name,
+		deps: deps,
+		run: func(ctx context.Context) error {
+			r.mu.Lock()
+			r.ran = append(r.ran, name)
+			r.mu.Unlock()
+			return err
+		},
+	}
+}
+
+func (r *stageRecorder) index(name string) int {
+	for i, ran := range r.ran {
+		if ran == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunStages(t *testing.T) {
+	var r stageRecorder
+	err := runStages(context.Background(), []*stage{
+		r.stage("release", nil, "api/push", "web/push"),
+		r.stage("api/build", nil),
+		r.stage("api/push", nil, "api/build"),
+		r.stage("web/build", nil),
+		r.stage("web/push", nil, "web/build"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.ran) != 5 {
+		t.Fatalf("expected 5 stages to run, got %v", r.ran)
+	}
+	for _, order := range [][2]string{{"api/build", "api/push"}, {"web/build", "web/push"}, {"api/push", "release"}, {"web/push", "release"}} {
+		if r.index(order[0]) > r.index(order[1]) {
+			t.Errorf("expected %s to run before %s, got %v", order[0], order[1], r.ran)
+		}
+	}
+}
+
+func TestRunStagesFailure(t *testing.T) {
+	var r stageRecorder
+	errBuild := errors.New("build failed")
+	err := runStages(context.Background(), []*stage{
+		r.stage("build", errBuild),
+		r.stage("push", nil, "build"),
+		r.stage("release", nil, "push"),
+	})
+	if err != errBuild {
+		t.Errorf("expected %v, got %v", errBuild, err)
+	}
+	if fmt.Sprint(r.ran) != "[build]" {
+		t.Errorf("expected the stages depending on the failed stage to be skipped, got %v", r.ran)
+	}
+}
+
+func TestRunStagesCancelled(t *testing.T) {
+	var r stageRecorder
+	ctx, cancel := context.WithCancel(context.Background())
+	build := r.stage("build", nil)
+	build.run = func(context.Context) error {
+		// the stage completes regardless, but no further stage may start.
+		cancel()
+		return nil
+	}
+	err := runStages(ctx, []*stage{build, r.stage("release", nil, "build")})
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if len(r.ran) != 0 {
+		t.Errorf("expected no stage to start after the cancellation, got %v", r.ran)
+	}
+
+	if err := runStages(ctx, []*stage{r.stage("build", nil)}); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	var cancelledTests = []struct {
+		err       error
+		cancelled bool
+	}{
+		{context.Canceled, true},
+		{fmt.Errorf("could not push: %v", context.Canceled), true},
+		{context.DeadlineExceeded, false},
+		{errors.New("denied"), false},
+	}
+
+	for _, tt := range cancelledTests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if actual := cancelled(tt.err); actual != tt.cancelled {
+				t.Errorf("expected %v but got %v", tt.cancelled, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+)
+
+// MultiPlatform reports whether env builds its image for several platforms. The image of
+// each platform is pushed under a tag of its own, see PlatformImage, and the tags of the
+// build refer to a manifest list covering all of them.
+func MultiPlatform(env *manifest.Environment) bool {
+	return len(env.Platforms) > 1
+}
+
+// PlatformImage returns the reference of the image built for platform by a multi-platform
+// build of image: the tag of image suffixed with the platform, such as app:1234-linux-arm64.
+func PlatformImage(image, platform string) string {
+	repository, tag := splitImage(image)
+	return repository + ":" + tag + "-" + strings.Replace(platform, "/", "-", -1)
+}
+
+// ParsePlatform splits a platform such as linux/arm64/v8 into its operating system,
+// architecture and optional variant.
+func ParsePlatform(platform string) (os, arch, variant string, err error) {
+	parts := strings.Split(platform, "/")
+	switch len(parts) {
+	case 3:
+		variant = parts[2]
+		fallthrough
+	case 2:
+		if parts[0] != "" && parts[1] != "" {
+			return parts[0], parts[1], variant, nil
+		}
+	}
+	return "", "", "", fmt.Errorf("invalid platform %q, expected os/arch[/variant]", platform)
+}
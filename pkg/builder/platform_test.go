@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+)
+
+func TestMultiPlatform(t *testing.T) {
+	var platformTests = []struct {
+		platforms []string
+		multi     bool
+	}{
+		{nil, false},
+		{[]string{"linux/arm64"}, false},
+		{[]string{"linux/amd64", "linux/arm64"}, true},
+	}
+
+	for _, tt := range platformTests {
+		if multi := MultiPlatform(&manifest.Environment{Platforms: tt.platforms}); multi != tt.multi {
+			t.Errorf("%v: expected %v, got %v", tt.platforms, tt.multi, multi)
+		}
+	}
+}
+
+func TestPlatformImage(t *testing.T) {
+	var imageTests = []struct {
+		image    string
+		platform string
+		expected string
+	}{
+		{"app:1234", "linux/arm64", "app:1234-linux-arm64"},
+		{"localhost:5000/app:1234", "linux/arm/v7", "localhost:5000/app:1234-linux-arm-v7"},
+	}
+
+	for _, tt := range imageTests {
+		if image := PlatformImage(tt.image, tt.platform); image != tt.expected {
+			t.Errorf("%s, %s: expected %q, got %q", tt.image, tt.platform, tt.expected, image)
+		}
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	var platformTests = []struct {
+		platform string
+		os       string
+		arch     string
+		variant  string
+		fails    bool
+	}{
+		{"linux/amd64", "linux", "amd64", "", false},
+		{"linux/arm/v7", "linux", "arm", "v7", false},
+		{"linux", "", "", "", true},
+		{"linux/", "", "", "", true},
+	}
+
+	for _, tt := range platformTests {
+		os, arch, variant, err := ParsePlatform(tt.platform)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: expected failure to be %v, got %v", tt.platform, tt.fails, err)
+		}
+		if os != tt.os || arch != tt.arch || variant != tt.variant {
+			t.Errorf("%s: expected %s/%s/%s, got %s/%s/%s", tt.platform, tt.os, tt.arch, tt.variant, os, arch, variant)
+		}
+	}
+}
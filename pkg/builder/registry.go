@@ -3,8 +3,14 @@ package builder
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/registry"
 )
 
 // DockerConfigEntryWithAuth is used solely for translating docker's AuthConfig token
@@ -49,3 +55,34 @@ func FromAuthConfig(ac types.AuthConfig) *DockerConfigEntryWithAuth {
 		ServerAddress: ac.ServerAddress,
 	}
 }
+
+// AuthTokenFromDockerConfig returns the docker auth token for the registry of image from
+// the docker CLI configuration file, for container builders which do not talk to a
+// docker daemon.
+func AuthTokenFromDockerConfig(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	host := reference.Domain(named)
+	if host == "docker.io" {
+		host = registry.IndexServer
+	}
+	authConfig, err := config.LoadDefaultConfigFile(ioutil.Discard).GetAuthConfig(host)
+	if err != nil {
+		return "", err
+	}
+	return command.EncodeAuthToBase64(authConfig)
+}
+
+// DockerConfigJSON returns the contents of a docker config.json file holding the
+// credentials in authToken for registry.
+func DockerConfigJSON(registry, authToken string) ([]byte, error) {
+	regAuth, err := FromAuthConfigToken(authToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert auth token for registry %s: %v", registry, err)
+	}
+	return json.Marshal(map[string]interface{}{
+		"auths": map[string]*DockerConfigEntryWithAuth{registry: regAuth},
+	})
+}
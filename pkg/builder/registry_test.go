@@ -31,3 +31,17 @@ func TestFromAuthConfigToken(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerConfigJSON(t *testing.T) {
+	js, err := DockerConfigJSON("myregistry.azurecr.io", "eyJ1c2VybmFtZSI6InVzZXJuYW1lIiwicGFzc3dvcmQiOiJwYXNzd29yZCJ9Cg==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"auths":{"myregistry.azurecr.io":{"username":"username","password":"password"}}}`
+	if string(js) != expected {
+		t.Errorf("expected %s, got %s", expected, js)
+	}
+	if _, err := DockerConfigJSON("myregistry.azurecr.io", "badbase64input"); err == nil {
+		t.Error("expected an invalid auth token to fail")
+	}
+}
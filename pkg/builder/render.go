@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+// hookAnnotation marks the chart hooks, which are left out of rendered releases.
+const hookAnnotation = "helm.sh/hook"
+
+// installOrder is the order in which objects are created, so that the objects others depend
+// on exist first. Objects of other kinds are created last.
+var installOrder = []string{
+	"Namespace",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ServiceAccount",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// document is a single object of a rendered chart.
+type document struct {
+	// source is the template the object was rendered from.
+	source  string
+	kind    string
+	hook    bool
+	content string
+}
+
+// RenderChart renders ch with config client-side, returning the manifest of the release in
+// install order and without hooks, along with the rendered notes of the chart.
+func RenderChart(ch *chart.Chart, config *chart.Config, options chartutil.ReleaseOptions, caps *chartutil.Capabilities) (rendered, notes string, err error) {
+	if err := chartutil.ProcessRequirementsEnabled(ch, config); err != nil {
+		return "", "", err
+	}
+	if err := chartutil.ProcessRequirementsImportValues(ch); err != nil {
+		return "", "", err
+	}
+	vals, err := chartutil.ToRenderValuesCaps(ch, config, options, caps)
+	if err != nil {
+		return "", "", err
+	}
+	files, err := engine.New().Render(ch, vals)
+	if err != nil {
+		return "", "", fmt.Errorf("could not render chart: %v", err)
+	}
+
+	docs, err := splitFiles(files)
+	if err != nil {
+		return "", "", err
+	}
+	return joinDocuments(docs), files[path.Join(ch.Metadata.Name, "templates", "NOTES.txt")], nil
+}
+
+// splitFiles splits the rendered templates of a chart into the objects they define, in the
+// order they should be created. Notes, empty documents and hooks are left out.
+func splitFiles(files map[string]string) ([]*document, error) {
+	var docs []*document
+	for name, content := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			continue
+		}
+		for _, content := range documentSeparator.Split(content, -1) {
+			if strings.TrimSpace(content) == "" {
+				continue
+			}
+			var head struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Annotations map[string]string `json:"annotations"`
+				} `json:"metadata"`
+			}
+			if err := yaml.Unmarshal([]byte(content), &head); err != nil {
+				return nil, fmt.Errorf("could not parse %s: %v", name, err)
+			}
+			_, hook := head.Metadata.Annotations[hookAnnotation]
+			docs = append(docs, &document{source: name, kind: head.Kind, hook: hook, content: content})
+		}
+	}
+	sortDocuments(docs)
+	kept := docs[:0]
+	for _, doc := range docs {
+		if !doc.hook {
+			kept = append(kept, doc)
+		}
+	}
+	return kept, nil
+}
+
+// sortDocuments sorts docs in install order, then by the template they were rendered from.
+func sortDocuments(docs []*document) {
+	rank := func(kind string) int {
+		for i, k := range installOrder {
+			if k == kind {
+				return i
+			}
+		}
+		return len(installOrder)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if ri, rj := rank(docs[i].kind), rank(docs[j].kind); ri != rj {
+			return ri < rj
+		}
+		return docs[i].source < docs[j].source
+	})
+}
+
+// joinDocuments joins docs into the manifest of a release, recording the template each
+// object was rendered from the way Tiller does.
+func joinDocuments(docs []*document) string {
+	var b bytes.Buffer
+	for _, doc := range docs {
+		fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", doc.source, doc.content)
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFiles(t *testing.T) {
+	files := map[string]string{
+		"app/templates/NOTES.txt":       "Thanks for installing app",
+		"app/templates/_helpers.tpl":    "\n",
+		"app/templates/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app",
+		"app/templates/service.yaml":    "apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app",
+		"app/templates/test.yaml":       "apiVersion: v1\nkind: Pod\nmetadata:\n  name: app-test\n  annotations:\n    helm.sh/hook: test-success",
+		"app/templates/widget.yaml":     "---\napiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: app",
+	}
+
+	docs, err := splitFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, doc := range docs {
+		kinds = append(kinds, doc.kind)
+	}
+	expected := []string{"ConfigMap", "Service", "Deployment", "Widget"}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("expected objects %v, got %v", expected, kinds)
+	}
+
+	manifest := joinDocuments(docs[:1])
+	if expected := "---\n# Source: app/templates/service.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"; manifest != expected {
+		t.Errorf("expected manifest %q, got %q", expected, manifest)
+	}
+}
+
+func TestSplitFilesInvalid(t *testing.T) {
+	if _, err := splitFiles(map[string]string{"app/templates/bad.yaml": "kind: [Deployment"}); err == nil {
+		t.Error("expected an error parsing an invalid template")
+	}
+}
@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"os"
+
+	"github.com/Azure/draft/pkg/storage"
+	"golang.org/x/net/context"
+)
+
+// Prune deletes the builds of the application specified by appName which are no longer
+// retained by the builder's retention policy, along with their log files and image archives.
+func (b *Builder) Prune(ctx context.Context, appName string) ([]*storage.Object, error) {
+	return b.prune(ctx, appName)
+}
+
+// prune is Prune, leaving the files of keep in place even when keep is not in the store.
+//
+// Every build of an environment writes its image archive to the same path, so an archive is
+// only removed when no retained build recorded it.
+func (b *Builder) prune(ctx context.Context, appName string, keep ...*storage.Object) ([]*storage.Object, error) {
+	pruned, err := storage.Prune(ctx, b.Storage, appName, b.Retention)
+	if len(pruned) == 0 {
+		return pruned, err
+	}
+	retained := make(map[string]bool)
+	builds, gerr := b.Storage.GetBuilds(ctx, appName)
+	if gerr != nil {
+		// without the retained builds, an archive still in use cannot be told apart.
+		if err == nil {
+			err = gerr
+		}
+		builds = nil
+	}
+	for _, obj := range append(builds, keep...) {
+		retained[obj.GetImageArchiveRef()] = true
+	}
+	for _, obj := range pruned {
+		refs := []string{obj.GetLogsFileRef()}
+		if ref := obj.GetImageArchiveRef(); gerr == nil && !retained[ref] {
+			refs = append(refs, ref)
+		}
+		for _, ref := range refs {
+			if ref == "" {
+				continue
+			}
+			if rerr := os.Remove(ref); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return pruned, err
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/Azure/draft/pkg/storage/inprocess"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+)
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "draft-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldArchive, archive, current := file("old.tar"), file("image.tar"), file("current.tar")
+	builds := []*storage.Object{
+		{BuildID: "foo1", LogsFileRef: file("foo1.log"), ImageArchiveRef: oldArchive},
+		{BuildID: "foo2", LogsFileRef: file("foo2.log"), ImageArchiveRef: archive},
+		{BuildID: "foo3", LogsFileRef: file("foo3.log"), ImageArchiveRef: current},
+		{BuildID: "foo4", LogsFileRef: file("foo4.log"), ImageArchiveRef: archive},
+	}
+	b := &Builder{Storage: inprocess.NewStore(), Retention: storage.RetentionPolicy{MaxBuilds: 1}}
+	for i, build := range builds {
+		if err := b.Storage.UpdateBuild(context.Background(), "app", build); err != nil {
+			t.Fatal(err)
+		}
+		build.CreatedAt, _ = ptypes.TimestampProto(time.Now().Add(time.Duration(i-len(builds)) * time.Minute))
+	}
+
+	// the build being saved is not in the store, yet its archive must be left in place.
+	unstored := &storage.Object{BuildID: "foo5", ImageArchiveRef: current}
+	pruned, err := b.prune(context.Background(), "app", unstored)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if len(pruned) != 3 {
+		t.Fatalf("expected 3 builds to be pruned, got %d", len(pruned))
+	}
+	for path, kept := range map[string]bool{
+		builds[0].LogsFileRef: false,
+		builds[1].LogsFileRef: false,
+		builds[2].LogsFileRef: false,
+		builds[3].LogsFileRef: true,
+		oldArchive:            false,
+		archive:               true,
+		current:               true,
+	} {
+		if _, err := os.Stat(path); kept != (err == nil) {
+			t.Errorf("expected %s to be kept: %t, got %v", filepath.Base(path), kept, err)
+		}
+	}
+}
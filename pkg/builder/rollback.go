@@ -0,0 +1,141 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	"k8s.io/helm/pkg/strvals"
+)
+
+// RollbackTarget returns the build from builds to roll back to.
+//
+// If buildID is empty, the latest successful build before the most recent one is chosen.
+func RollbackTarget(builds []*storage.Object, buildID string) (*storage.Object, error) {
+	if buildID != "" {
+		for _, build := range builds {
+			if build.GetBuildID() != buildID {
+				continue
+			}
+			if !succeeded(build) {
+				return nil, fmt.Errorf("build %q did not deploy successfully and cannot be rolled back to", buildID)
+			}
+			return build, nil
+		}
+		return nil, fmt.Errorf("build %q not found", buildID)
+	}
+	sorted := make([]*storage.Object, len(builds))
+	copy(sorted, builds)
+	storage.SortByCreatedAt(sorted)
+	for i := len(sorted) - 2; i >= 0; i-- {
+		if succeeded(sorted[i]) {
+			return sorted[i], nil
+		}
+	}
+	return nil, ErrNoRollbackTarget
+}
+
+// succeeded reports whether build deployed a release.
+//
+// Builds recorded before their outcome was stored are considered successful if they
+// have a release.
+func succeeded(build *storage.Object) bool {
+	if build.GetStatus() == "" {
+		return build.GetRelease() != ""
+	}
+	return build.GetStatus() == statusName(SummarySuccess)
+}
+
+// Rollback redeploys the release produced by target without rebuilding it. The rollback
+// is recorded in storage as a new build which refers back to target.
+func (b *Builder) Rollback(ctx context.Context, env *manifest.Environment, target *storage.Object) (*storage.Object, error) {
+	const stageDesc = "Rolling Back Release"
+
+	obj := &storage.Object{
+		BuildID:     b.ID,
+		ContextID:   target.GetContextID(),
+		Images:      target.GetImages(),
+		Environment: target.GetEnvironment(),
+		VcsRevision: target.GetVcsRevision(),
+		VcsDirty:    target.GetVcsDirty(),
+		RollbackTo:  target.GetBuildID(),
+	}
+	start := time.Now()
+	rls, err := b.rollback(ctx, env, target)
+	code := SummarySuccess
+	if err != nil {
+		code = SummaryFailure
+	} else {
+		obj.Release = rls.Name
+		obj.ReleaseVersion = rls.Version
+	}
+	recordOutcome(obj, []*storage.Stage{{
+		Name:     stageDesc,
+		Status:   statusName(code),
+		Duration: ptypes.DurationProto(time.Since(start)),
+	}}, err)
+	if serr := b.Storage.UpdateBuild(ctx, env.Name, obj); serr != nil {
+		if err == nil {
+			err = fmt.Errorf("failed to store rollback: %v", serr)
+		}
+		return obj, err
+	}
+	if _, perr := b.Prune(ctx, env.Name); perr != nil && err == nil {
+		err = fmt.Errorf("failed to prune build history: %v", perr)
+	}
+	return obj, err
+}
+
+// rollback rolls the release back to the revision deployed by target. Builds recorded
+// before release revisions were stored are redeployed by upgrading the release to
+// their image instead.
+func (b *Builder) rollback(ctx context.Context, env *manifest.Environment, target *storage.Object) (*release.Release, error) {
+	if DeploysObjects(env) {
+		return nil, ErrRollbackObjects
+	}
+	if version := target.GetReleaseVersion(); version > 0 {
+		rls, err := b.ReleaseBackend.Rollback(ctx, env, version)
+		if err != nil {
+			return nil, fmt.Errorf("could not roll back release to revision %d: %v", version, err)
+		}
+		return rls, nil
+	}
+
+	images := target.GetImages()
+	if len(images) == 0 {
+		return nil, fmt.Errorf("build %q has no release revision or image to roll back to", target.GetBuildID())
+	}
+	repository, tag := splitImage(images[0])
+	vals := make(chartutil.Values)
+	if err := strvals.ParseInto(fmt.Sprintf("image.repository=%s,image.tag=%s", repository, tag), vals); err != nil {
+		return nil, err
+	}
+	raw, err := vals.YAML()
+	if err != nil {
+		return nil, err
+	}
+	current, err := b.ReleaseBackend.Release(env)
+	if err != nil {
+		return nil, fmt.Errorf("could not get release %q: %v", env.Name, err)
+	}
+	rls, err := b.ReleaseBackend.Upgrade(ctx, env, current.Chart, []byte(raw), true)
+	if err != nil {
+		return nil, fmt.Errorf("could not upgrade release to image %s: %v", images[0], err)
+	}
+	return rls, nil
+}
+
+// splitImage splits an image reference into its repository and tag.
+func splitImage(image string) (repository, tag string) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, "latest"
+	}
+	return image[:i], image[i+1:]
+}
@@ -0,0 +1,57 @@
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/golang/protobuf/ptypes"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	build := func(id, status string, age time.Duration) *storage.Object {
+		created, _ := ptypes.TimestampProto(time.Now().Add(-age))
+		return &storage.Object{BuildID: id, Release: "app", Status: status, CreatedAt: created}
+	}
+	builds := []*storage.Object{
+		build("foo4", "success", 1*time.Minute),
+		build("foo1", "success", 4*time.Minute),
+		build("foo3", "failure", 2*time.Minute),
+		build("foo2", "success", 3*time.Minute),
+	}
+
+	target, err := RollbackTarget(builds, "")
+	if err != nil {
+		t.Fatalf("failed to find rollback target: %v", err)
+	}
+	if target.BuildID != "foo2" {
+		t.Errorf("expected rollback target %q, got %q", "foo2", target.BuildID)
+	}
+
+	if target, err = RollbackTarget(builds, "foo1"); err != nil {
+		t.Fatalf("failed to find rollback target: %v", err)
+	}
+	if target.BuildID != "foo1" {
+		t.Errorf("expected rollback target %q, got %q", "foo1", target.BuildID)
+	}
+
+	if _, err = RollbackTarget(builds, "foo3"); err == nil {
+		t.Error("expected rolling back to a failed build to fail")
+	}
+	if _, err = RollbackTarget(builds[:1], ""); err != ErrNoRollbackTarget {
+		t.Errorf("expected %v, got %v", ErrNoRollbackTarget, err)
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	for image, expected := range map[string][2]string{
+		"app:abc":                    {"app", "abc"},
+		"localhost:5000/app:abc":     {"localhost:5000/app", "abc"},
+		"localhost:5000/app":         {"localhost:5000/app", "latest"},
+		"docker.io/myusername/app:1": {"docker.io/myusername/app", "1"},
+	} {
+		if repository, tag := splitImage(image); repository != expected[0] || tag != expected[1] {
+			t.Errorf("expected %s to split into %v, got %s and %s", image, expected, repository, tag)
+		}
+	}
+}
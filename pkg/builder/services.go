@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Azure/draft/pkg/storage"
+	"golang.org/x/net/context"
+)
+
+// loadServices archives the build context of every service of a multi-service application.
+//
+// The environment of a service is the environment of the application, with the image named
+// after the application and the service, and the Dockerfile and build args of the service.
+func loadServices(ctx *Context) error {
+	if ctx.Env.ImageArchive != "" {
+		return errors.New("image-archive cannot be used together with services")
+	}
+	// the chart is resolved against the application directory rather than the service's.
+	chartDir, err := filepath.Abs(filepath.Join(ctx.AppDir, deployDir(ctx.Env)))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for i, svc := range ctx.Env.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("service #%d has no name", i+1)
+		}
+		if seen[svc.Name] {
+			return fmt.Errorf("service %q is defined more than once", svc.Name)
+		}
+		seen[svc.Name] = true
+
+		env := *ctx.Env
+		env.Name = ctx.Env.Name + "-" + svc.Name
+		env.Dockerfile = svc.Dockerfile
+		if env.Dockerfile == "" {
+			env.Dockerfile = DefaultDockerfile
+		}
+		env.Chart = chartDir
+		env.Manifests, env.Kustomize = "", ""
+		env.Services = nil
+		env.ImageBuildArgs = make(map[string]string)
+		for k, v := range ctx.Env.ImageBuildArgs {
+			env.ImageBuildArgs[k] = v
+		}
+		for k, v := range svc.ImageBuildArgs {
+			env.ImageBuildArgs[k] = v
+		}
+
+		svcCtx := &Context{
+			Env:     &env,
+			EnvName: ctx.EnvName,
+			AppDir:  filepath.Join(ctx.AppDir, svc.Context),
+			Service: svc.Name,
+		}
+		if err := archiveSrc(svcCtx); err != nil {
+			return fmt.Errorf("service %s: %v", svc.Name, err)
+		}
+		ctx.Services = append(ctx.Services, svcCtx)
+	}
+	return nil
+}
+
+// newServiceContext prepares the state of the build of one of the services of an application.
+// The build object of a service is not stored; its images are recorded on the application's.
+func newServiceContext(b *Builder, buildCtx *Context) *AppContext {
+	ctxtID := buildCtx.Archive.Digest()
+	images := imageNames(buildCtx.Env, ctxtID)
+	return &AppContext{
+		Obj: &storage.Object{
+			BuildID:   b.ID,
+			ContextID: ctxtID,
+			Images:    images,
+		},
+		ID:        b.ID,
+		Bldr:      b,
+		Ctx:       buildCtx,
+		Images:    images,
+		MainImage: images[0],
+	}
+}
+
+// labelService returns a channel forwarding the summaries of the stages of a service to out,
+// labelled with the name of the service, and a function closing it once the stages have run.
+func labelService(svc *AppContext, out chan<- *Summary) (chan<- *Summary, func()) {
+	summaries := make(chan *Summary)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for summary := range summaries {
+			summary.StageDesc = fmt.Sprintf("%s (%s)", summary.StageDesc, svc.Ctx.Service)
+			out <- summary
+		}
+	}()
+	return summaries, func() {
+		close(summaries)
+		<-done
+	}
+}
+
+// serviceErrors prefixes the errors of the stages of a service with the name of the service,
+// so a failed build tells which of the services failed.
+func serviceErrors(svc *AppContext, stages ...*stage) {
+	for _, s := range stages {
+		run := s.run
+		s.run = func(ctx context.Context) error {
+			if err := run(ctx); err != nil {
+				return fmt.Errorf("service %s: %v", svc.Ctx.Service, err)
+			}
+			return nil
+		}
+	}
+}
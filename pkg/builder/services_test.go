@@ -0,0 +1,133 @@
+package builder
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"golang.org/x/net/context"
+)
+
+func TestLoadServices(t *testing.T) {
+	ctx := &Context{
+		AppDir: filepath.Join("testdata", "services"),
+		Env: &manifest.Environment{
+			Name:           "app",
+			ImageBuildArgs: map[string]string{"VERSION": "1", "TARGET": "prod"},
+			Services: []*manifest.Service{
+				{Name: "api", Context: "api", ImageBuildArgs: map[string]string{"TARGET": "api"}},
+				{Name: "web", Context: "web", Dockerfile: "Dockerfile.web"},
+			},
+		},
+	}
+	if err := loadServices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	if len(ctx.Services) != 2 {
+		t.Fatalf("expected 2 service build contexts, got %d", len(ctx.Services))
+	}
+	api, web := ctx.Services[0], ctx.Services[1]
+	if api.Service != "api" || api.Env.Name != "app-api" {
+		t.Errorf("expected service %q with image name %q, got %q and %q", "api", "app-api", api.Service, api.Env.Name)
+	}
+	if expected := map[string]string{"VERSION": "1", "TARGET": "api"}; !reflect.DeepEqual(api.Env.ImageBuildArgs, expected) {
+		t.Errorf("expected build args %v, got %v", expected, api.Env.ImageBuildArgs)
+	}
+	if api.Env.Dockerfile != DefaultDockerfile {
+		t.Errorf("expected Dockerfile %q, got %q", DefaultDockerfile, api.Env.Dockerfile)
+	}
+	if web.Env.Dockerfile != "Dockerfile.web" {
+		t.Errorf("expected Dockerfile %q, got %q", "Dockerfile.web", web.Env.Dockerfile)
+	}
+	if ctx.Env.ImageBuildArgs["TARGET"] != "prod" {
+		t.Error("expected the build args of the environment to be left untouched")
+	}
+	for _, svc := range ctx.Services {
+		if svc.Archive == nil || svc.Archive.Size() == 0 {
+			t.Errorf("expected an archive for service %s", svc.Service)
+		}
+	}
+	if string(api.Archive.Digest()) == string(web.Archive.Digest()) {
+		t.Error("expected the services to be built from different build contexts")
+	}
+
+	ctx.Env.Services = append(ctx.Env.Services, &manifest.Service{Name: "api", Context: "api"})
+	ctx.Services = nil
+	if err := loadServices(ctx); err == nil {
+		t.Error("expected services with the same name to fail")
+	}
+	ctx.Close()
+}
+
+// fakeServiceBuilder reports a build stage for every image it builds, failing to build
+// the images named in fail.
+type fakeServiceBuilder struct {
+	ContainerBuilder
+	fail string
+}
+
+func (f fakeServiceBuilder) Build(ctx context.Context, app *AppContext, out chan<- *Summary) (err error) {
+	const stageDesc = "Building Docker Image"
+	defer Complete(app.ID, stageDesc, out, &err)
+	Summarize(app.ID, stageDesc, out)("started", SummaryStarted)
+	if strings.Contains(app.MainImage, f.fail) {
+		return errors.New("build failed")
+	}
+	return nil
+}
+
+func (f fakeServiceBuilder) Push(ctx context.Context, app *AppContext, out chan<- *Summary) error {
+	return nil
+}
+
+func TestServiceStages(t *testing.T) {
+	service := func(name string) *AppContext {
+		return &AppContext{
+			ID:        "foo",
+			MainImage: "app-" + name + ":0123abcd",
+			Ctx:       &Context{Env: &manifest.Environment{}, Service: name},
+		}
+	}
+	app := &AppContext{ID: "foo", Ctx: &Context{}, Services: []*AppContext{service("api"), service("web")}}
+
+	build := func(b *Builder) ([]string, error) {
+		out := make(chan *Summary, 10)
+		stages, done := b.stages(app, out)
+		// leave out the release, which is the last stage.
+		err := runStages(context.Background(), stages[:len(stages)-1])
+		done()
+		close(out)
+		var descs []string
+		for summary := range out {
+			if summary.StatusCode != SummaryStarted {
+				descs = append(descs, summary.StageDesc+": "+statusName(summary.StatusCode))
+			}
+		}
+		sort.Strings(descs)
+		return descs, err
+	}
+
+	stages, err := build(&Builder{ContainerBuilder: fakeServiceBuilder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Building Docker Image (api): success", "Building Docker Image (web): success"}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("expected stages %v, got %v", expected, stages)
+	}
+
+	stages, err = build(&Builder{ContainerBuilder: fakeServiceBuilder{fail: "web"}})
+	if err == nil || !strings.Contains(err.Error(), "service web") {
+		t.Errorf("expected the build of service web to fail, got %v", err)
+	}
+	expected = []string{"Building Docker Image (api): success", "Building Docker Image (web): failure"}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("expected stages %v, got %v", expected, stages)
+	}
+}
@@ -16,6 +16,10 @@ const (
 	SummarySuccess
 	// SummaryFailure means that `draft up` has failed. Usually this can be followed up by checking the build logs.
 	SummaryFailure
+	// SummaryCached means that a stage was skipped because its result already exists, such as an image built before.
+	SummaryCached
+	// SummaryCancelled means that a stage was aborted because the build was cancelled, such as on Ctrl-C.
+	SummaryCancelled
 )
 
 // SummaryStatusCodeName is the relation between summary status code enums and their respective names.
@@ -26,6 +30,8 @@ var SummaryStatusCodeName = map[int]string{
 	3: "ONGOING",
 	4: "SUCCESS",
 	5: "FAILURE",
+	6: "CACHED",
+	7: "CANCELLED",
 }
 
 // Summary is the message returned when executing a draft up.
@@ -0,0 +1,81 @@
+package tiller
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"k8s.io/helm/pkg/helm"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/proto/hapi/release"
+)
+
+// Backend releases applications through Tiller, which renders the chart, applies it and keeps
+// the release history in the cluster.
+type Backend struct {
+	Helm helm.Interface
+}
+
+// Release returns the latest revision of the release of env.
+func (b *Backend) Release(env *manifest.Environment) (*release.Release, error) {
+	res, err := b.Helm.ReleaseContent(env.Name)
+	if err != nil {
+		// The returned error is a grpc error that wraps the message from the original error.
+		// So we're stuck doing string matching against the wrapped error, which is nested inside
+		// of the grpc message.
+		if strings.Contains(err.Error(), "not found") {
+			return nil, builder.ErrReleaseNotFound
+		}
+		return nil, errors.New(grpc.ErrorDesc(err))
+	}
+	return res.Release, nil
+}
+
+// Install installs chart as the release of env.
+func (b *Backend) Install(ctx context.Context, env *manifest.Environment, chart *chart.Chart, vals []byte) (*release.Release, error) {
+	res, err := b.Helm.InstallReleaseFromChart(chart, env.Namespace,
+		helm.ReleaseName(env.Name),
+		helm.ValueOverrides(vals),
+		helm.InstallWait(env.Wait),
+	)
+	if err != nil {
+		return nil, errors.New(grpc.ErrorDesc(err))
+	}
+	return res.Release, nil
+}
+
+// Upgrade upgrades the release of env to chart.
+func (b *Backend) Upgrade(ctx context.Context, env *manifest.Environment, chart *chart.Chart, vals []byte, reuseValues bool) (*release.Release, error) {
+	res, err := b.Helm.UpdateReleaseFromChart(env.Name, chart,
+		helm.UpdateValueOverrides(vals),
+		helm.ReuseValues(reuseValues),
+		helm.UpgradeWait(env.Wait),
+	)
+	if err != nil {
+		return nil, errors.New(grpc.ErrorDesc(err))
+	}
+	return res.Release, nil
+}
+
+// Rollback rolls the release of env back to the given revision.
+func (b *Backend) Rollback(ctx context.Context, env *manifest.Environment, version int32) (*release.Release, error) {
+	res, err := b.Helm.RollbackRelease(env.Name,
+		helm.RollbackVersion(version),
+		helm.RollbackWait(env.Wait),
+	)
+	if err != nil {
+		return nil, errors.New(grpc.ErrorDesc(err))
+	}
+	return res.Release, nil
+}
+
+// Delete deletes and purges the release of env.
+func (b *Backend) Delete(ctx context.Context, env *manifest.Environment) error {
+	if _, err := b.Helm.DeleteRelease(env.Name, helm.DeletePurge(true)); err != nil {
+		return errors.New(grpc.ErrorDesc(err))
+	}
+	return nil
+}
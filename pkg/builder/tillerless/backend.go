@@ -0,0 +1,245 @@
+package tillerless
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"golang.org/x/net/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/kube"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	"k8s.io/helm/pkg/releaseutil"
+	"k8s.io/helm/pkg/storage"
+	"k8s.io/helm/pkg/storage/driver"
+	"k8s.io/helm/pkg/timeconv"
+)
+
+// timeout is how long to wait for the objects of a release to become ready, in seconds.
+const timeout = 300
+
+// Backend releases applications without Tiller. Charts are rendered client-side, the
+// rendered objects are applied to the cluster with the credentials of the user, and the
+// release history is kept in secrets in the namespace of each release.
+//
+// Chart hooks are not run.
+type Backend struct {
+	Kube k8s.Interface
+	// Client creates, updates and deletes the objects of a release.
+	Client *kube.Client
+}
+
+// New returns a backend talking to the cluster of the given client configuration.
+func New(config clientcmd.ClientConfig, client k8s.Interface) *Backend {
+	return &Backend{
+		Kube:   client,
+		Client: kube.New(config),
+	}
+}
+
+// storage returns the release history of the given namespace. The helm storage drivers
+// are written against the internal clientset rather than client-go.
+func (b *Backend) storage(ns string) (*storage.Storage, error) {
+	client, err := b.Client.ClientSet()
+	if err != nil {
+		return nil, fmt.Errorf("could not get a kube client: %v", err)
+	}
+	return storage.Init(driver.NewSecrets(client.Core().Secrets(ns))), nil
+}
+
+// Release returns the latest revision of the release of env.
+func (b *Backend) Release(env *manifest.Environment) (*release.Release, error) {
+	history, err := b.history(env)
+	if err != nil {
+		return nil, err
+	}
+	return history[len(history)-1], nil
+}
+
+// history returns the revisions of the release of env, oldest first.
+func (b *Backend) history(env *manifest.Environment) ([]*release.Release, error) {
+	store, err := b.storage(namespace(env))
+	if err != nil {
+		return nil, err
+	}
+	history, err := store.History(env.Name)
+	// the storage drivers report missing releases with errors of their own making.
+	if (err != nil && strings.Contains(err.Error(), "not found")) || (err == nil && len(history) == 0) {
+		return nil, builder.ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	releaseutil.SortByRevision(history)
+	return history, nil
+}
+
+// Install installs chart as the release of env.
+func (b *Backend) Install(ctx context.Context, env *manifest.Environment, ch *chart.Chart, vals []byte) (*release.Release, error) {
+	rls, err := b.render(env, ch, &chart.Config{Raw: string(vals)}, 1)
+	if err != nil {
+		return nil, err
+	}
+	rls.Info.Status.Code = release.Status_PENDING_INSTALL
+	rls.Info.Description = "Install complete"
+	store, err := b.storage(namespace(env))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Create(rls); err != nil {
+		return nil, fmt.Errorf("could not record release: %v", err)
+	}
+	err = b.Client.Create(namespace(env), bytes.NewBufferString(rls.Manifest), timeout, env.Wait)
+	return rls, b.record(store, rls, nil, err)
+}
+
+// Upgrade upgrades the release of env to chart.
+func (b *Backend) Upgrade(ctx context.Context, env *manifest.Environment, ch *chart.Chart, vals []byte, reuseValues bool) (*release.Release, error) {
+	current, err := b.Release(env)
+	if err != nil {
+		return nil, err
+	}
+	config := &chart.Config{Raw: string(vals)}
+	if reuseValues {
+		if config, err = mergeConfig(current.Config, vals); err != nil {
+			return nil, err
+		}
+	}
+	rls, err := b.render(env, ch, config, current.Version+1)
+	if err != nil {
+		return nil, err
+	}
+	rls.Info.FirstDeployed = current.Info.FirstDeployed
+	rls.Info.Status.Code = release.Status_PENDING_UPGRADE
+	rls.Info.Description = "Upgrade complete"
+	return rls, b.update(env, current, rls)
+}
+
+// Rollback redeploys the given revision of the release of env as a new revision.
+func (b *Backend) Rollback(ctx context.Context, env *manifest.Environment, version int32) (*release.Release, error) {
+	current, err := b.Release(env)
+	if err != nil {
+		return nil, err
+	}
+	store, err := b.storage(namespace(env))
+	if err != nil {
+		return nil, err
+	}
+	target, err := store.Get(env.Name, version)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revision %d: %v", version, err)
+	}
+	now := timeconv.Now()
+	rls := &release.Release{
+		Name:      env.Name,
+		Namespace: namespace(env),
+		Chart:     target.Chart,
+		Config:    target.Config,
+		Manifest:  target.Manifest,
+		Version:   current.Version + 1,
+		Info: &release.Info{
+			FirstDeployed: current.Info.FirstDeployed,
+			LastDeployed:  now,
+			Status: &release.Status{
+				Code:  release.Status_PENDING_ROLLBACK,
+				Notes: target.Info.Status.Notes,
+			},
+			Description: fmt.Sprintf("Rollback to %d", version),
+		},
+	}
+	return rls, b.update(env, current, rls)
+}
+
+// update applies the difference between the objects of current and rls, and records rls as
+// the deployed revision of the release.
+func (b *Backend) update(env *manifest.Environment, current, rls *release.Release) error {
+	store, err := b.storage(namespace(env))
+	if err != nil {
+		return err
+	}
+	if err := store.Create(rls); err != nil {
+		return fmt.Errorf("could not record release: %v", err)
+	}
+	err = b.Client.Update(namespace(env),
+		bytes.NewBufferString(current.Manifest),
+		bytes.NewBufferString(rls.Manifest),
+		false, false, timeout, env.Wait)
+	return b.record(store, rls, current, err)
+}
+
+// record marks rls as deployed, or failed if applying its objects failed with err. The
+// revision rls replaces, if any, is marked as superseded once rls is deployed.
+func (b *Backend) record(store *storage.Storage, rls, superseded *release.Release, err error) error {
+	rls.Info.LastDeployed = timeconv.Now()
+	if err != nil {
+		rls.Info.Status.Code = release.Status_FAILED
+		rls.Info.Description = err.Error()
+		if uerr := store.Update(rls); uerr != nil {
+			return fmt.Errorf("%v (could not record release: %v)", err, uerr)
+		}
+		return err
+	}
+	rls.Info.Status.Code = release.Status_DEPLOYED
+	if superseded != nil && superseded.Info.Status.Code == release.Status_DEPLOYED {
+		superseded.Info.Status.Code = release.Status_SUPERSEDED
+		if err := store.Update(superseded); err != nil {
+			return fmt.Errorf("could not record release: %v", err)
+		}
+	}
+	if err := store.Update(rls); err != nil {
+		return fmt.Errorf("could not record release: %v", err)
+	}
+	return nil
+}
+
+// Delete deletes the objects of the release of env and its history.
+func (b *Backend) Delete(ctx context.Context, env *manifest.Environment) error {
+	history, err := b.history(env)
+	if err != nil {
+		return err
+	}
+	current := history[len(history)-1]
+	if err := b.Client.Delete(namespace(env), bytes.NewBufferString(current.Manifest)); err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("could not delete the objects of release %q: %v", env.Name, err)
+	}
+	store, err := b.storage(namespace(env))
+	if err != nil {
+		return err
+	}
+	for _, rls := range history {
+		if _, err := store.Delete(rls.Name, rls.Version); err != nil {
+			return fmt.Errorf("could not delete revision %d of release %q: %v", rls.Version, env.Name, err)
+		}
+	}
+	return nil
+}
+
+// capabilities returns the capabilities of the cluster exposed to chart templates.
+func (b *Backend) capabilities() (*chartutil.Capabilities, error) {
+	kubeVersion, err := b.Kube.Discovery().ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the Kubernetes version: %v", err)
+	}
+	groups, err := b.Kube.Discovery().ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the Kubernetes API versions: %v", err)
+	}
+	return &chartutil.Capabilities{
+		APIVersions: chartutil.NewVersionSet(metav1.ExtractGroupVersions(groups)...),
+		KubeVersion: kubeVersion,
+	}, nil
+}
+
+// namespace returns the namespace of the release of env.
+func namespace(env *manifest.Environment) string {
+	if env.Namespace == "" {
+		return manifest.DefaultNamespace
+	}
+	return env.Namespace
+}
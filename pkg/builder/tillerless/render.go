@@ -0,0 +1,81 @@
+package tillerless
+
+import (
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/draft/manifest"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/proto/hapi/release"
+	"k8s.io/helm/pkg/timeconv"
+)
+
+// render renders ch with config client-side into a new revision of the release of env.
+func (b *Backend) render(env *manifest.Environment, ch *chart.Chart, config *chart.Config, revision int32) (*release.Release, error) {
+	caps, err := b.capabilities()
+	if err != nil {
+		return nil, err
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      env.Name,
+		Namespace: namespace(env),
+		Time:      timeconv.Now(),
+		Revision:  int(revision),
+		IsInstall: revision == 1,
+		IsUpgrade: revision > 1,
+	}
+	rendered, notes, err := builder.RenderChart(ch, config, options, caps)
+	if err != nil {
+		return nil, err
+	}
+	now := timeconv.Now()
+	return &release.Release{
+		Name:      env.Name,
+		Namespace: namespace(env),
+		Chart:     ch,
+		Config:    config,
+		Manifest:  rendered,
+		Version:   revision,
+		Info: &release.Info{
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Status:        &release.Status{Notes: notes},
+		},
+	}, nil
+}
+
+// mergeConfig merges the values in vals into those of config.
+func mergeConfig(config *chart.Config, vals []byte) (*chart.Config, error) {
+	var base chartutil.Values
+	if config != nil {
+		var err error
+		if base, err = chartutil.ReadValues([]byte(config.Raw)); err != nil {
+			return nil, err
+		}
+	}
+	overrides, err := chartutil.ReadValues(vals)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := chartutil.Values(mergeValues(base, overrides)).YAML()
+	if err != nil {
+		return nil, err
+	}
+	return &chart.Config{Raw: raw}, nil
+}
+
+// mergeValues merges src into dst recursively, values in src taking precedence.
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		if next, ok := v.(map[string]interface{}); ok {
+			if prev, ok := dst[k].(map[string]interface{}); ok {
+				dst[k] = mergeValues(prev, next)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,36 @@
+package tillerless
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	dst := map[string]interface{}{
+		"replicaCount": 1,
+		"image": map[string]interface{}{
+			"repository": "app",
+			"tag":        "v1",
+			"pullPolicy": "IfNotPresent",
+		},
+	}
+	src := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "v2"},
+		"debug": true,
+	}
+	expected := map[string]interface{}{
+		"replicaCount": 1,
+		"debug":        true,
+		"image": map[string]interface{}{
+			"repository": "app",
+			"tag":        "v2",
+			"pullPolicy": "IfNotPresent",
+		},
+	}
+	if merged := mergeValues(dst, src); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %v, got %v", expected, merged)
+	}
+	if merged := mergeValues(nil, src); !reflect.DeepEqual(merged, src) {
+		t.Errorf("expected %v, got %v", src, merged)
+	}
+}
@@ -0,0 +1,22 @@
+package builder
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// vcsRevision returns the git commit checked out in dir and whether the working tree
+// has uncommitted changes.
+//
+// An empty revision is returned if dir is not a git repository or git is not installed.
+func vcsRevision(dir string) (revision string, dirty bool) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	revision = strings.TrimSpace(string(out))
+	if out, err = exec.Command("git", "-C", dir, "status", "--porcelain").Output(); err == nil {
+		dirty = len(strings.TrimSpace(string(out))) > 0
+	}
+	return revision, dirty
+}
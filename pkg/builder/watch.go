@@ -2,98 +2,134 @@ package builder
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/Azure/draft/pkg/draft/ignore"
+	"github.com/Azure/draft/pkg/draft/manifest"
 	"github.com/rjeczalik/notify"
 	"golang.org/x/net/context"
-	"k8s.io/helm/pkg/ignore"
 )
 
-const ignoreFileName = ".draftignore"
-
 // Watch watches for inotify events in the build context's application directory, returning events
-// to the stream
+// to the stream.
+//
+// Events are debounced by the environment's watch delay: a new build context is only loaded and
+// sent once no further changes have been observed for that long. Build contexts for changes which
+// only affect the chart or the values set in draft.toml are marked as ChartOnly.
+//
+// Changes to paths ignored by .dockerignore or .draftignore are not watched, except for the files
+// which configure the build and release: draft.toml, the Dockerfile, the chart and the ignore
+// files themselves.
 func (buildctx *Context) Watch(ctx context.Context, stream chan<- *Context) (err error) {
-	var rules *ignore.Rules
-	if rules, err = ignore.ParseFile(ignoreFileName); err != nil {
-		// only fail if exists and can't be parsed
-		if _, err = os.Stat(ignoreFileName); os.IsExist(err) {
-			return fmt.Errorf("could not load ignore watch list: %v", err)
-		}
+	rules, err := ignore.ParseDir(buildctx.AppDir)
+	if err != nil {
+		return fmt.Errorf("could not load ignore watch list: %v", err)
+	}
+	delay := time.Duration(buildctx.Env.WatchDelay) * time.Second
+	if delay <= 0 {
+		delay = manifest.DefaultWatchDelaySeconds * time.Second
+	}
+	// the environment is compared as written in draft.toml, as the receiver of the stream
+	// may configure it further.
+	env, err := loadEnv(buildctx.AppDir, buildctx.EnvName)
+	if err != nil {
+		return err
+	}
+	appDir, err := filepath.Abs(buildctx.AppDir)
+	if err != nil {
+		return err
 	}
 	defer close(stream)
-	return watch(ctx, buildctx.AppDir, rules, func() error {
+	// writing the image archive must not trigger another build.
+	archive := buildctx.ImageArchivePath()
+	return watch(ctx, appDir, delay, rules, watchedFiles(env), archive, func(changes []string) error {
 		b, err := LoadWithEnv(buildctx.AppDir, buildctx.EnvName)
 		if err != nil {
 			return err
 		}
-		stream <- b
-		return nil
+		next := *b.Env
+		b.ChartOnly = chartOnly(appDir, env, &next, changes)
+		env = &next
+		select {
+		case stream <- b:
+			return nil
+		case <-ctx.Done():
+			b.Close()
+			return ctx.Err()
+		}
 	})
 }
 
-func watch(ctx context.Context, dir string, rules *ignore.Rules, action func() error) error {
+func watch(ctx context.Context, dir string, delay time.Duration, rules *ignore.Rules, keep []string, archive string, action func(changes []string) error) error {
 	infoc := make(chan notify.EventInfo, 1)
-	if err := notify.Watch(dir, infoc, notify.All); err != nil {
+	// the trailing "..." tells notify to watch the directory tree recursively.
+	if err := notify.Watch(filepath.Join(dir, "..."), infoc, notify.All); err != nil {
 		return fmt.Errorf("could not watch %q: %v", dir, err)
 	}
+	defer notify.Stop(infoc)
 
-	evtc := make(chan struct{})
-	go func() {
-		for info := range infoc {
-			prefix := strings.TrimPrefix(info.Path(), dir+"/")
-			fi, err := os.Stat(info.Path())
-			if os.IsNotExist(err) {
-				// create dummy file info for removed file or directory
-				fi = removedFileInfo(filepath.Base(info.Path()))
-			}
-			// only rebuild if the changed file isn't in our ignore list
-			if rules != nil && rules.Ignore(prefix, fi) {
-				continue
-			}
-			// ignore manually everything inside the .git/ directory as
-			// helm ignore file doesn't have directory and whole content
-			// (subdir of subdir) ignore support yet.
-			if filepath.HasPrefix(prefix, ".git/") {
-				continue
-			}
-			evtc <- struct{}{}
-		}
-		close(evtc)
-	}()
-	defer func() {
-		notify.Stop(infoc)
-		close(evtc)
-	}()
+	// pending fires once no changes have been observed for the duration of the delay.
+	var (
+		pending <-chan time.Time
+		changes []string
+	)
 	for {
 		select {
-		case <-evtc:
-			if err := action(); err != nil {
+		case info := <-infoc:
+			if ignored(dir, rules, keep, info.Path()) || (archive != "" && info.Path() == archive) {
+				continue
+			}
+			changes = append(changes, info.Path())
+			pending = time.After(delay)
+		case <-pending:
+			pending = nil
+			if err := action(changes); err != nil {
 				return err
 			}
+			changes = nil
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
-// removedFileInfo fake file info for ignore library only use IsDir() in negative pattern
-type removedFileInfo string
-
-func (n removedFileInfo) Name() string     { return string(n) }
-func (removedFileInfo) Size() int64        { return 0 }
-func (removedFileInfo) Mode() os.FileMode  { return 0 }
-func (removedFileInfo) ModTime() time.Time { return time.Time{} }
-func (removedFileInfo) IsDir() bool        { return false }
-func (removedFileInfo) Sys() interface{}   { return nil }
+// watchedFiles returns the paths, relative to the application directory, of the files which
+// configure the build and release of env, including the Dockerfiles of its services.
+func watchedFiles(env *manifest.Environment) []string {
+	dockerfile := env.Dockerfile
+	if dockerfile == "" {
+		dockerfile = DefaultDockerfile
+	}
+	files := []string{"draft.toml", filepath.Clean(dockerfile), filepath.Clean(deployDir(env)), ignore.DockerIgnore, ignore.DraftIgnore}
+	for _, svc := range env.Services {
+		dockerfile := svc.Dockerfile
+		if dockerfile == "" {
+			dockerfile = DefaultDockerfile
+		}
+		files = append(files, filepath.Join(svc.Context, dockerfile))
+	}
+	return files
+}
 
-// r, err := ignore.ParseFile(ignoreFileName)
-// if err != nil {
-// 	// only fail if file can't be parsed but exists
-// 	if _, err := os.Stat(ignoreFileName); os.IsExist(err) {
-// 		log.Fatalf("could not load ignore watch list %v", err)
-// 	}
-// }
+// ignored reports whether a change to path should not trigger a rebuild. Changes to the files
+// in keep, relative to dir, are never ignored.
+func ignored(dir string, rules *ignore.Rules, keep []string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	// ignore manually everything inside the .git/ directory, whether or
+	// not the ignore files mention it.
+	prefix := filepath.ToSlash(rel)
+	if prefix == ".git" || strings.HasPrefix(prefix, ".git/") {
+		return true
+	}
+	for _, k := range keep {
+		if rel == k || strings.HasPrefix(rel, k+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return rules.Ignore(rel)
+}
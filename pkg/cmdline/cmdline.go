@@ -28,6 +28,9 @@ type cmdline struct {
 	done chan struct{}
 	once sync.Once
 	err  error
+	// mu serializes writes to stdout so that build output is not interleaved
+	// with a spinner frame.
+	mu sync.Mutex
 }
 
 // Init initializes the cmdline interface.
@@ -40,6 +43,7 @@ func (cli *cmdline) Init(rootctx context.Context, opts ...Option) {
 		initTerminal(out)
 	} else {
 		NoColor()(&cli.opts)
+		Plain()(&cli.opts)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -118,13 +122,15 @@ func Display(ctx context.Context, app string, summaries <-chan *builder.Summary,
 			if summary.StatusCode == builder.SummaryFailure {
 				failed = true
 			}
+			if summary.StatusCode == builder.SummaryLogging && cli.opts.verbose {
+				cli.printLog(summary.StatusText)
+			}
 			if ch, ok := ongoing[summary.StageDesc]; !ok {
 				ch = make(chan builder.SummaryStatusCode, 1)
 				ongoing[summary.StageDesc] = ch
 				wg.Add(1)
 				go func(desc string, ch chan builder.SummaryStatusCode, wg *sync.WaitGroup) {
 					progress(&cli, app, desc, ch)
-					delete(ongoing, desc)
 					wg.Done()
 				}(summary.StageDesc, ch, &wg)
 			} else {
@@ -137,12 +143,17 @@ func Display(ctx context.Context, app string, summaries <-chan *builder.Summary,
 }
 
 func progress(cli *cmdline, app, desc string, codes <-chan builder.SummaryStatusCode) {
+	if cli.opts.plain {
+		plainProgress(cli, app, desc, codes)
+		return
+	}
 	start := time.Now()
 	done := make(chan builder.SummaryStatusCode, 1)
 	go func() {
 		defer close(done)
 		for code := range codes {
-			if code == builder.SummarySuccess || code == builder.SummaryFailure {
+			switch code {
+			case builder.SummarySuccess, builder.SummaryFailure, builder.SummaryCached, builder.SummaryCancelled:
 				done <- code
 			}
 		}
@@ -152,7 +163,12 @@ func progress(cli *cmdline, app, desc string, codes <-chan builder.SummaryStatus
 	i := 0
 	for {
 		select {
-		case code := <-done:
+		case code, ok := <-done:
+			if !ok {
+				// the display was stopped before the stage completed.
+				fmt.Fprintln(cli.opts.stdout)
+				return
+			}
 			switch code {
 			case builder.SummarySuccess:
 				fmt.Fprintf(cli.opts.stdout, "\r%s: %s  (%.4fs)\n", cyan(app), passStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
@@ -160,15 +176,63 @@ func progress(cli *cmdline, app, desc string, codes <-chan builder.SummaryStatus
 			case builder.SummaryFailure:
 				fmt.Fprintf(cli.opts.stderr, "\r%s: %s  (%.4fs)\n", cyan(app), failStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
 				return
+			case builder.SummaryCached:
+				fmt.Fprintf(cli.opts.stdout, "\r%s: %s  (%.4fs)\n", cyan(app), cachedStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+				return
+			case builder.SummaryCancelled:
+				fmt.Fprintf(cli.opts.stderr, "\r%s: %s  (%.4fs)\n", cyan(app), cancelledStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+				return
 			}
 		default:
+			cli.mu.Lock()
 			fmt.Fprintf(cli.opts.stdout, "\r%s %c", m, s[i%len(s)])
+			cli.mu.Unlock()
 			time.Sleep(50 * time.Millisecond)
 			i++
 		}
 	}
 }
 
+// printLog prints a line of build output beneath the active stages. On a terminal the
+// spinner line is cleared first; the spinner is redrawn below the output.
+func (cli *cmdline) printLog(text string) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.opts.plain {
+		fmt.Fprintf(cli.opts.stdout, "    %s\n", text)
+		return
+	}
+	fmt.Fprintf(cli.opts.stdout, "\r\x1b[2K    %s\n", text)
+}
+
+// plainProgress reports the start and the outcome of a stage on a line each, for output
+// which is not displayed in a terminal such as CI logs.
+func plainProgress(cli *cmdline, app, desc string, codes <-chan builder.SummaryStatusCode) {
+	start := time.Now()
+	fmt.Fprintf(cli.opts.stdout, "%s: %s: started\n", cyan(app), yellow(desc))
+	// keep receiving until the display stops so that it never blocks on this stage.
+	done := false
+	for code := range codes {
+		if done {
+			continue
+		}
+		switch code {
+		case builder.SummarySuccess:
+			fmt.Fprintf(cli.opts.stdout, "%s: %s  (%.4fs)\n", cyan(app), passStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+			done = true
+		case builder.SummaryFailure:
+			fmt.Fprintf(cli.opts.stderr, "%s: %s  (%.4fs)\n", cyan(app), failStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+			done = true
+		case builder.SummaryCached:
+			fmt.Fprintf(cli.opts.stdout, "%s: %s  (%.4fs)\n", cyan(app), cachedStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+			done = true
+		case builder.SummaryCancelled:
+			fmt.Fprintf(cli.opts.stderr, "%s: %s  (%.4fs)\n", cyan(app), cancelledStr(desc, cli.opts.displayEmoji), time.Since(start).Seconds())
+			done = true
+		}
+	}
+}
+
 func passStr(msg string, displayEmoji bool) string {
 	return fmt.Sprintf("%s: %s", green(msg), concatStrAndEmoji("SUCCESS", " ⚓ ", displayEmoji))
 }
@@ -177,6 +241,14 @@ func failStr(msg string, displayEmoji bool) string {
 	return fmt.Sprintf("%s: %s", red(msg), concatStrAndEmoji("FAIL", " ❌ ", displayEmoji))
 }
 
+func cachedStr(msg string, displayEmoji bool) string {
+	return fmt.Sprintf("%s: %s", green(msg), concatStrAndEmoji("CACHED", " ⚓ ", displayEmoji))
+}
+
+func cancelledStr(msg string, displayEmoji bool) string {
+	return fmt.Sprintf("%s: %s", yellow(msg), concatStrAndEmoji("CANCELLED", " 🛑 ", displayEmoji))
+}
+
 func concatStrAndEmoji(text string, emoji string, displayEmoji bool) string {
 	var concatStr strings.Builder
 	concatStr.WriteString(text)
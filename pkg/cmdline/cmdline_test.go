@@ -0,0 +1,91 @@
+package cmdline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Azure/draft/pkg/builder"
+	"golang.org/x/net/context"
+)
+
+func TestDisplayPlain(t *testing.T) {
+	summaries := make(chan *builder.Summary, 4)
+	summary := builder.Summarize("foo", "Building Docker Image", summaries)
+	summary("started", builder.SummaryStarted)
+	summary("step 1/2", builder.SummaryLogging)
+	summary("success", builder.SummarySuccess)
+	close(summaries)
+
+	var stdout, stderr bytes.Buffer
+	// neither writer is a terminal, so the plain renderer is selected.
+	Display(context.Background(), "app", summaries, WithStdout(&stdout), WithStderr(&stderr), WithBuildID("foo"))
+
+	if strings.Contains(stdout.String(), "\r") {
+		t.Errorf("expected no spinner frames in plain output, got %q", stdout.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	expected := []string{
+		"Draft Up Started: 'app': foo",
+		"app: Building Docker Image: started",
+		"app: Building Docker Image: SUCCESS",
+		"Inspect the logs with `draft logs foo`",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), stdout.String())
+	}
+	for i := range expected {
+		if !strings.HasPrefix(lines[i], expected[i]) {
+			t.Errorf("expected line %d to start with %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestDisplayVerbose(t *testing.T) {
+	summaries := make(chan *builder.Summary, 4)
+	summary := builder.Summarize("foo", "Building Docker Image", summaries)
+	summary("started", builder.SummaryStarted)
+	summary("Step 1/2 : FROM alpine", builder.SummaryLogging)
+	summary("success", builder.SummarySuccess)
+	close(summaries)
+
+	var stdout bytes.Buffer
+	Display(context.Background(), "app", summaries, WithStdout(&stdout), WithStderr(&stdout), Verbose())
+
+	if !strings.Contains(stdout.String(), "    Step 1/2 : FROM alpine\n") {
+		t.Errorf("expected build output to be streamed, got %q", stdout.String())
+	}
+}
+
+func TestDisplayCached(t *testing.T) {
+	summaries := make(chan *builder.Summary, 2)
+	summary := builder.Summarize("foo", "Building Docker Image", summaries)
+	summary("started", builder.SummaryStarted)
+	summary("image app:0123abcd already exists", builder.SummaryCached)
+	close(summaries)
+
+	var stdout, stderr bytes.Buffer
+	Display(context.Background(), "app", summaries, WithStdout(&stdout), WithStderr(&stderr), WithBuildID("foo"))
+
+	if !strings.Contains(stdout.String(), "app: Building Docker Image: CACHED") {
+		t.Errorf("expected the build stage to be reported as cached, got %q", stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected no failures, got %q", stderr.String())
+	}
+}
+
+func TestDisplayCancelled(t *testing.T) {
+	summaries := make(chan *builder.Summary, 2)
+	summary := builder.Summarize("foo", "Pushing Docker Image", summaries)
+	summary("started", builder.SummaryStarted)
+	summary("cancelled", builder.SummaryCancelled)
+	close(summaries)
+
+	var stdout, stderr bytes.Buffer
+	Display(context.Background(), "app", summaries, WithStdout(&stdout), WithStderr(&stderr), WithBuildID("foo"))
+
+	if !strings.Contains(stderr.String(), "app: Pushing Docker Image: CANCELLED") {
+		t.Errorf("expected the push stage to be reported as cancelled, got %q", stderr.String())
+	}
+}
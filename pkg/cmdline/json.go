@@ -0,0 +1,88 @@
+package cmdline
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/storage"
+	"golang.org/x/net/context"
+)
+
+// event is a single line of the JSON output of a draft client operation. Its keys are
+// camelCase, like the ones of `draft history -o json`.
+type event struct {
+	Time    string  `json:"time"`
+	App     string  `json:"app"`
+	BuildID string  `json:"buildID"`
+	Stage   string  `json:"stage,omitempty"`
+	Status  string  `json:"status,omitempty"`
+	Text    string  `json:"text,omitempty"`
+	Result  *result `json:"result,omitempty"`
+}
+
+// result is the outcome of a draft up, emitted as the last event of a build.
+type result struct {
+	Status          string   `json:"status"`
+	Images          []string `json:"images,omitempty"`
+	Release         string   `json:"release,omitempty"`
+	ReleaseRevision int32    `json:"releaseRevision,omitempty"`
+	FailedStage     string   `json:"failedStage,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// DisplayJSON is the machine-readable counterpart of Display. Every summary yielded by
+// the draft state machine is written to stdout as a newline-delimited JSON event.
+func DisplayJSON(ctx context.Context, app string, summaries <-chan *builder.Summary, opts ...Option) {
+	var o options
+	DefaultOpts()(&o)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	enc := json.NewEncoder(o.stdout)
+	for {
+		select {
+		case summary, ok := <-summaries:
+			if !ok {
+				return
+			}
+			enc.Encode(&event{
+				Time:    now(),
+				App:     app,
+				BuildID: summary.BuildID,
+				Stage:   summary.StageDesc,
+				Status:  builder.SummaryStatusCodeName[int(summary.StatusCode)],
+				Text:    summary.StatusText,
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DisplayJSONResult writes the outcome of the build recorded in obj to stdout as a
+// JSON event, completing the stream written by DisplayJSON.
+func DisplayJSONResult(app string, obj *storage.Object, opts ...Option) error {
+	var o options
+	DefaultOpts()(&o)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return json.NewEncoder(o.stdout).Encode(&event{
+		Time:    now(),
+		App:     app,
+		BuildID: obj.GetBuildID(),
+		Result: &result{
+			Status:          obj.GetStatus(),
+			Images:          obj.GetImages(),
+			Release:         obj.GetRelease(),
+			ReleaseRevision: obj.GetReleaseVersion(),
+			FailedStage:     obj.GetFailedStage(),
+			Error:           obj.GetErrorMessage(),
+		},
+	})
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,64 @@
+package cmdline
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/draft/pkg/builder"
+	"github.com/Azure/draft/pkg/storage"
+	"golang.org/x/net/context"
+)
+
+func TestDisplayJSON(t *testing.T) {
+	summaries := make(chan *builder.Summary, 2)
+	summary := builder.Summarize("foo", "Building Docker Image", summaries)
+	summary("started", builder.SummaryStarted)
+	summary("success", builder.SummarySuccess)
+	close(summaries)
+
+	var out bytes.Buffer
+	DisplayJSON(context.Background(), "app", summaries, WithStdout(&out))
+	DisplayJSONResult("app", &storage.Object{BuildID: "foo", Status: "success", Release: "app", Images: []string{"app:bar"}}, WithStdout(&out))
+
+	dec := json.NewDecoder(&out)
+	var events []event
+	for dec.More() {
+		var e event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if e := events[1]; e.App != "app" || e.BuildID != "foo" || e.Stage != "Building Docker Image" || e.Status != "SUCCESS" || e.Time == "" {
+		t.Errorf("unexpected stage event: %+v", e)
+	}
+	if r := events[2].Result; r == nil || r.Status != "success" || r.Release != "app" || len(r.Images) != 1 {
+		t.Errorf("unexpected result event: %+v", r)
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	var out bytes.Buffer
+	DisplayJSONResult("app", &storage.Object{BuildID: "foo", Status: "failure", ReleaseVersion: 2, FailedStage: "Building Docker Image"}, WithStdout(&out))
+
+	// the keys match the ones of `draft history -o json`.
+	var e struct {
+		BuildID string                 `json:"buildID"`
+		Result  map[string]interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if e.BuildID != "foo" {
+		t.Errorf("expected buildID %q, got %q", "foo", e.BuildID)
+	}
+	for _, key := range []string{"status", "releaseRevision", "failedStage"} {
+		if _, ok := e.Result[key]; !ok {
+			t.Errorf("expected result key %q, got %v", key, e.Result)
+		}
+	}
+}
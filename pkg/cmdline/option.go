@@ -15,6 +15,8 @@ type options struct {
 	stdout       io.Writer
 	buildID      string
 	displayEmoji bool
+	plain        bool
+	verbose      bool
 }
 
 // DefaultOpts is a convenience wrapper that enumerates and configures the set of default
@@ -53,6 +55,24 @@ func NoColor() Option {
 	}
 }
 
+// Plain returns an Option that renders progress as a single line per stage transition
+// instead of animated spinners.
+//
+// Plain output is used automatically when stdout is not a terminal.
+func Plain() Option {
+	return func(opts *options) {
+		opts.plain = true
+	}
+}
+
+// Verbose returns an Option that streams the build output carried by logging summaries
+// beneath the stage that produced it.
+func Verbose() Option {
+	return func(opts *options) {
+		opts.verbose = true
+	}
+}
+
 // WithBuildID returns an Option that set the build id to use.
 func WithBuildID(buildID string) Option {
 	return func(opts *options) {
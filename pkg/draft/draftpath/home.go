@@ -31,6 +31,11 @@ func (h Home) Logs() string {
 	return h.Path("logs")
 }
 
+// Storage returns the path to the Draft build history storage.
+func (h Home) Storage() string {
+	return h.Path("storage")
+}
+
 // Plugins returns the path to the Draft plugins.
 func (h Home) Plugins() string {
 	return h.Path("plugins")
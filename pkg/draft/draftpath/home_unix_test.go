@@ -19,4 +19,5 @@ func TestDraftHome(t *testing.T) {
 	isEq(t, ph.String(), "/r")
 	isEq(t, ph.Packs(), "/r/packs")
 	isEq(t, ph.Plugins(), "/r/plugins")
+	isEq(t, ph.Storage(), "/r/storage")
 }
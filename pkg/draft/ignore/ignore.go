@@ -0,0 +1,170 @@
+// Package ignore merges the .dockerignore and .draftignore of an application into a single
+// set of rules, shared by the build context, the file watcher and language detection.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/builder/dockerignore"
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+const (
+	// DockerIgnore is the name of the file listing the paths excluded from the docker build context.
+	DockerIgnore = ".dockerignore"
+	// DraftIgnore is the name of the file listing the paths ignored by draft.
+	DraftIgnore = ".draftignore"
+)
+
+// Rules is a set of ignore patterns in the .dockerignore syntax.
+type Rules struct {
+	patterns []string
+	matcher  *fileutils.PatternMatcher
+	// rules holds a matcher for each of the patterns, in order.
+	rules []rule
+}
+
+type rule struct {
+	matcher   *fileutils.PatternMatcher
+	exception bool
+}
+
+// New returns the rules for patterns in the .dockerignore syntax.
+func New(patterns []string) (*Rules, error) {
+	matcher, err := fileutils.NewPatternMatcher(patterns)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rules{patterns: patterns, matcher: matcher}
+	for _, pattern := range patterns {
+		exception := strings.HasPrefix(pattern, "!")
+		m, err := fileutils.NewPatternMatcher([]string{strings.TrimPrefix(pattern, "!")})
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, rule{matcher: m, exception: exception})
+	}
+	return r, nil
+}
+
+// ParseDir reads the .dockerignore and .draftignore in dir, either of which may be missing.
+//
+// The patterns of .draftignore come last, so its exceptions can re-include paths excluded by
+// .dockerignore.
+func ParseDir(dir string) (*Rules, error) {
+	dockerPatterns, err := parseFile(filepath.Join(dir, DockerIgnore), dockerignore.ReadAll)
+	if err != nil {
+		return nil, err
+	}
+	draftPatterns, err := parseFile(filepath.Join(dir, DraftIgnore), ReadDraftIgnore)
+	if err != nil {
+		return nil, err
+	}
+	return New(append(dockerPatterns, draftPatterns...))
+}
+
+func parseFile(path string, parse func(io.Reader) ([]string, error)) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	patterns, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return patterns, nil
+}
+
+// ReadDraftIgnore reads a .draftignore and translates its patterns into the .dockerignore syntax.
+//
+// .draftignore follows the syntax of .helmignore: a pattern without a slash matches a file or
+// directory of that name at any depth, a leading slash anchors the pattern to the application
+// directory and a trailing slash matches directories. Unlike .helmignore, `**` matches any
+// number of directories.
+func ReadDraftIgnore(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if pattern, ok := draftPattern(scanner.Text()); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+func draftPattern(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+	var negate string
+	if strings.HasPrefix(line, "!") {
+		negate, line = "!", line[1:]
+	}
+	// a directory pattern excludes everything in the directory, so the trailing slash can go.
+	line = strings.TrimSuffix(line, "/")
+	switch {
+	case line == "":
+		return "", false
+	case strings.HasPrefix(line, "/"):
+		line = strings.TrimPrefix(line, "/")
+	case !strings.Contains(line, "/") && !strings.HasPrefix(line, "**"):
+		line = "**/" + line
+	}
+	line = filepath.Clean(filepath.FromSlash(line))
+	if line == "." {
+		return "", false
+	}
+	return negate + line, true
+}
+
+// Patterns returns the patterns of the rules in the .dockerignore syntax.
+func (r *Rules) Patterns() []string {
+	patterns := make([]string, len(r.patterns))
+	copy(patterns, r.patterns)
+	return patterns
+}
+
+// Exceptions reports whether the rules have exceptions, which may re-include paths inside
+// an ignored directory.
+func (r *Rules) Exceptions() bool {
+	return r.matcher.Exclusions()
+}
+
+// Ignore reports whether path, relative to the application directory, is ignored. As in the
+// docker build context, a path is ignored if it or any of its parent directories matches the
+// rules, unless a later exception matches it or one of its parent directories.
+func (r *Rules) Ignore(path string) bool {
+	path = filepath.Clean(path)
+	if path == "." {
+		return false
+	}
+	// the matcher only tests the parent directory as deep as each pattern, so a pattern such
+	// as **/node_modules would miss the files in node_modules at other depths.
+	var paths []string
+	for p := path; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		paths = append(paths, p)
+	}
+	var ignored bool
+	for _, rule := range r.rules {
+		for _, p := range paths {
+			if match, err := rule.matcher.Matches(p); err == nil && match {
+				ignored = !rule.exception
+				break
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,126 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDraftIgnore(t *testing.T) {
+	const draftignore = `# editor files
+*.swp
+.git*
+
+/build/
+docs/*.md
+**/node_modules
+!keep.swp
+/
+`
+	patterns, err := ReadDraftIgnore(strings.NewReader(draftignore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"**/*.swp", "**/.git*", "build", "docs/*.md", "**/node_modules", "!**/keep.swp"}
+	for i := range expected {
+		expected[i] = filepath.FromSlash(expected[i])
+	}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("expected %v but got %v", expected, patterns)
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "draft-ignore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, DockerIgnore), []byte("Dockerfile\ncharts/\n*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, DraftIgnore), []byte("*.swp\nnode_modules/\n!debug.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ignoreTests = []struct {
+		path    string
+		ignored bool
+	}{
+		{"Dockerfile", true},
+		{"charts/app/values.yaml", true},
+		{"error.log", true},
+		{"debug.log", false},
+		{"src/main.swp", true},
+		{"node_modules", true},
+		{"node_modules/index.js", true},
+		{"web/node_modules/left-pad/index.js", true},
+		{"apps/web/node_modules/left-pad/lib/index.js", true},
+		{"src/main.go", false},
+		{".", false},
+	}
+
+	for _, tt := range ignoreTests {
+		t.Run(tt.path, func(t *testing.T) {
+			if actual := rules.Ignore(filepath.FromSlash(tt.path)); actual != tt.ignored {
+				t.Errorf("expected %v but got %v", tt.ignored, actual)
+			}
+		})
+	}
+}
+
+func TestIgnoreExceptions(t *testing.T) {
+	rules, err := New([]string{"*", "!src/main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rules.Exceptions() {
+		t.Error("expected the rules to have exceptions")
+	}
+
+	var ignoreTests = []struct {
+		path    string
+		ignored bool
+	}{
+		{"Dockerfile", true},
+		{"src", true},
+		{"src/main.go", false},
+		{"src/util.go", true},
+	}
+
+	for _, tt := range ignoreTests {
+		t.Run(tt.path, func(t *testing.T) {
+			if actual := rules.Ignore(filepath.FromSlash(tt.path)); actual != tt.ignored {
+				t.Errorf("expected %v but got %v", tt.ignored, actual)
+			}
+		})
+	}
+}
+
+func TestParseDirMissingFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "draft-ignore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rules, err := ParseDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Patterns()) != 0 {
+		t.Errorf("expected no patterns, got %v", rules.Patterns())
+	}
+	if rules.Ignore("main.go") {
+		t.Error("expected nothing to be ignored")
+	}
+}
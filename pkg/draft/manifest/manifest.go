@@ -27,23 +27,47 @@ type Manifest struct {
 
 // Environment represents the environment for a given app at build time
 type Environment struct {
-	Name              string            `toml:"name,omitempty"`
-	ContainerBuilder  string            `toml:"container-builder,omitempty"`
-	Registry          string            `toml:"registry,omitempty"`
-	ResourceGroupName string            `toml:"resource-group-name,omitempty"`
-	BuildTarPath      string            `toml:"build-tar,omitempty"`
-	ChartTarPath      string            `toml:"chart-tar,omitempty"`
-	Namespace         string            `toml:"namespace,omitempty"`
-	Values            []string          `toml:"set,omitempty"`
-	Wait              bool              `toml:"wait"`
-	Watch             bool              `toml:"watch"`
-	WatchDelay        int               `toml:"watch-delay,omitempty"`
-	OverridePorts     []string          `toml:"override-ports,omitempty"`
-	AutoConnect       bool              `toml:"auto-connect"`
-	CustomTags        []string          `toml:"custom-tags,omitempty"`
-	Dockerfile        string            `toml:"dockerfile"`
-	Chart             string            `toml:"chart"`
-	ImageBuildArgs    map[string]string `toml:"image-build-args,omitempty"`
+	Name               string            `toml:"name,omitempty"`
+	ContainerBuilder   string            `toml:"container-builder,omitempty"`
+	Registry           string            `toml:"registry,omitempty"`
+	ResourceGroupName  string            `toml:"resource-group-name,omitempty"`
+	BuildTarPath       string            `toml:"build-tar,omitempty"`
+	ChartTarPath       string            `toml:"chart-tar,omitempty"`
+	Namespace          string            `toml:"namespace,omitempty"`
+	Values             []string          `toml:"set,omitempty"`
+	Wait               bool              `toml:"wait"`
+	Watch              bool              `toml:"watch"`
+	WatchDelay         int               `toml:"watch-delay,omitempty"`
+	OverridePorts      []string          `toml:"override-ports,omitempty"`
+	AutoConnect        bool              `toml:"auto-connect"`
+	CustomTags         []string          `toml:"custom-tags,omitempty"`
+	Dockerfile         string            `toml:"dockerfile"`
+	Chart              string            `toml:"chart"`
+	ImageBuildArgs     map[string]string `toml:"image-build-args,omitempty"`
+	HistoryMaxBuilds   int               `toml:"history-max-builds,omitempty"`
+	HistoryMaxAge      string            `toml:"history-max-age,omitempty"`
+	CacheFrom          []string          `toml:"cache-from,omitempty"`
+	CacheTo            []string          `toml:"cache-to,omitempty"`
+	ImageArchive       string            `toml:"image-archive,omitempty"`
+	ImageArchiveFormat string            `toml:"image-archive-format,omitempty"`
+	Platforms          []string          `toml:"platforms,omitempty"`
+	Services           []*Service        `toml:"services,omitempty"`
+	ReleaseBackend     string            `toml:"release-backend,omitempty"`
+	Manifests          string            `toml:"manifests,omitempty"`
+	Kustomize          string            `toml:"kustomize,omitempty"`
+}
+
+// Service is one of the images built for a multi-service application
+type Service struct {
+	// Name identifies the service. Its image is named after the application and the service,
+	// and its repository and tag are injected into the chart under the service's name.
+	Name string `toml:"name"`
+	// Context is the build context directory of the service, relative to the application directory.
+	Context string `toml:"context,omitempty"`
+	// Dockerfile is the Dockerfile of the service, relative to its build context directory.
+	Dockerfile string `toml:"dockerfile,omitempty"`
+	// ImageBuildArgs are added to the image build args of the environment.
+	ImageBuildArgs map[string]string `toml:"image-build-args,omitempty"`
 }
 
 // New creates a new manifest with the Environments intialized.
@@ -0,0 +1,224 @@
+// Package apply applies Kubernetes objects with server-side apply, and deletes them by label.
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// FieldManager is the manager of the fields applied by draft.
+	FieldManager = "draft"
+
+	// NamespaceLabel is the label recording, on cluster-scoped objects, the namespace they were
+	// applied along with, so that only the objects of that namespace are deleted.
+	NamespaceLabel = "draftNamespace"
+
+	// applyPatchType is the content type of server-side apply patches.
+	applyPatchType = types.PatchType("application/apply-patch+yaml")
+)
+
+// Applier applies objects to the cluster with server-side apply, taking ownership of the
+// fields set by the objects.
+type Applier struct {
+	Discovery discovery.DiscoveryInterface
+	// REST sends requests to arbitrary API paths.
+	REST rest.Interface
+}
+
+// New returns an applier talking to the cluster of client.
+func New(client kubernetes.Interface) *Applier {
+	return &Applier{
+		Discovery: client.Discovery(),
+		REST:      client.Discovery().RESTClient(),
+	}
+}
+
+// Apply creates or updates objs in order. Namespaced objects without a namespace are applied
+// to namespace, or to the default namespace if empty.
+func (a *Applier) Apply(namespace string, objs []*unstructured.Unstructured) error {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	for _, obj := range objs {
+		resource, err := a.resource(obj.GetAPIVersion(), obj.GetKind())
+		if err != nil {
+			return err
+		}
+		ns := ""
+		if resource.Namespaced {
+			if ns = obj.GetNamespace(); ns == "" {
+				ns = namespace
+			}
+		} else {
+			obj = namespaceLabelled(obj, namespace)
+		}
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		err = a.REST.Patch(applyPatchType).
+			AbsPath(resourcePath(obj.GetAPIVersion(), resource.Name, ns, obj.GetName())).
+			Param("fieldManager", FieldManager).
+			Param("force", "true").
+			Body(data).
+			Do().
+			Error()
+		if err != nil {
+			return fmt.Errorf("could not apply %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// Delete deletes the objects of every kind which match the label selector: the namespaced
+// objects in namespace, or in the default namespace if empty, and the cluster-scoped objects
+// applied along with that namespace.
+// Objects owned by other objects are left to the garbage collector.
+func (a *Applier) Delete(namespace, selector string) error {
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	lists, err := a.Discovery.ServerPreferredResources()
+	// the resources of the groups which could be discovered are still worth cleaning up.
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return fmt.Errorf("could not discover the resources of the cluster: %v", err)
+	}
+	for _, list := range lists {
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !hasVerbs(resource, "list", "delete") {
+				continue
+			}
+			ns, sel := "", clusterSelector(selector, namespace)
+			if resource.Namespaced {
+				ns, sel = namespace, selector
+			}
+			err := a.deleteResources(list.GroupVersion, resource.Name, ns, sel)
+			// users confined to a namespace cannot list, and so cannot have applied, most
+			// cluster-scoped resources.
+			if err != nil && !(ns == "" && apierrors.IsForbidden(err)) {
+				return fmt.Errorf("could not delete %s: %v", resource.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// namespaceLabelled returns a copy of the cluster-scoped obj labelled with namespace.
+func namespaceLabelled(obj *unstructured.Unstructured, namespace string) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[NamespaceLabel] = namespace
+	obj.SetLabels(labels)
+	return obj
+}
+
+// clusterSelector restricts selector to the cluster-scoped objects applied along with namespace.
+func clusterSelector(selector, namespace string) string {
+	return fmt.Sprintf("%s,%s=%s", selector, NamespaceLabel, namespace)
+}
+
+func (a *Applier) deleteResources(groupVersion, resource, namespace, selector string) error {
+	raw, err := a.REST.Get().
+		AbsPath(resourcePath(groupVersion, resource, namespace, "")).
+		Param("labelSelector", selector).
+		Do().
+		Raw()
+	if err != nil {
+		return err
+	}
+	var list struct {
+		Items []struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	opts, err := json.Marshal(&metav1.DeleteOptions{
+		TypeMeta:          metav1.TypeMeta{Kind: "DeleteOptions", APIVersion: "v1"},
+		PropagationPolicy: &propagation,
+	})
+	if err != nil {
+		return err
+	}
+	for _, item := range list.Items {
+		if len(item.Metadata.OwnerReferences) > 0 {
+			continue
+		}
+		err := a.REST.Delete().
+			AbsPath(resourcePath(groupVersion, resource, namespace, item.Metadata.Name)).
+			Body(opts).
+			Do().
+			Error()
+		if err != nil {
+			return fmt.Errorf("%s: %v", item.Metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+// resource returns the API resource of the given kind.
+func (a *Applier) resource(groupVersion, kind string) (*metav1.APIResource, error) {
+	list, err := a.Discovery.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the resources of %s: %v", groupVersion, err)
+	}
+	for i, resource := range list.APIResources {
+		// skip subresources such as deployments/scale, which share the kind of their parent.
+		if resource.Kind == kind && !strings.Contains(resource.Name, "/") {
+			return &list.APIResources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the cluster has no resource of kind %s in %s", kind, groupVersion)
+}
+
+// resourcePath returns the API path of the named object of a resource, or of the collection
+// of the resource if name is empty. Cluster-scoped resources have no namespace.
+func resourcePath(groupVersion, resource, namespace, name string) string {
+	prefix := "/apis"
+	// the core group is served from the legacy /api path.
+	if !strings.Contains(groupVersion, "/") {
+		prefix = "/api"
+	}
+	elems := []string{prefix, groupVersion}
+	if namespace != "" {
+		elems = append(elems, "namespaces", namespace)
+	}
+	elems = append(elems, resource)
+	if name != "" {
+		elems = append(elems, name)
+	}
+	return path.Join(elems...)
+}
+
+func hasVerbs(resource metav1.APIResource, verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, v := range resource.Verbs {
+			if v == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package apply
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestResourcePath(t *testing.T) {
+	var pathTests = []struct {
+		groupVersion, resource, namespace, name string
+		expected                                string
+	}{
+		{"v1", "configmaps", "default", "app", "/api/v1/namespaces/default/configmaps/app"},
+		{"apps/v1", "deployments", "dev", "app", "/apis/apps/v1/namespaces/dev/deployments/app"},
+		{"apps/v1", "deployments", "dev", "", "/apis/apps/v1/namespaces/dev/deployments"},
+		{"rbac.authorization.k8s.io/v1", "clusterroles", "", "app", "/apis/rbac.authorization.k8s.io/v1/clusterroles/app"},
+	}
+
+	for _, tt := range pathTests {
+		if actual := resourcePath(tt.groupVersion, tt.resource, tt.namespace, tt.name); actual != tt.expected {
+			t.Errorf("expected %q but got %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestHasVerbs(t *testing.T) {
+	resource := metav1.APIResource{Name: "pods", Verbs: metav1.Verbs{"get", "list", "delete"}}
+	if !hasVerbs(resource, "list", "delete") {
+		t.Error("expected pods to support list and delete")
+	}
+	if hasVerbs(resource, "list", "patch") {
+		t.Error("expected pods not to support patch")
+	}
+}
+
+func TestNamespaceLabelled(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetLabels(map[string]string{"draft": "app"})
+	labelled := namespaceLabelled(obj, "dev")
+	if ns := labelled.GetLabels()[NamespaceLabel]; ns != "dev" {
+		t.Errorf("expected the object to be labelled with namespace dev, got %q", ns)
+	}
+	if _, ok := obj.GetLabels()[NamespaceLabel]; ok {
+		t.Error("expected the original object to be left unlabelled")
+	}
+}
+
+func TestClusterSelector(t *testing.T) {
+	expected := "draft=app,buildID!=1234,draftNamespace=dev"
+	if actual := clusterSelector("draft=app,buildID!=1234", "dev"); actual != expected {
+		t.Errorf("expected %q but got %q", expected, actual)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Azure/draft/pkg/draft/ignore"
 	"github.com/Azure/draft/pkg/osutil"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,6 +18,9 @@ import (
 var (
 	isIgnored                 func(string) bool
 	isDetectedInGitAttributes func(filename string) string
+	// walkIgnoredDirs is set when exceptions of the ignore rules may re-include files of
+	// ignored directories.
+	walkIgnoredDirs bool
 )
 
 // used for displaying results
@@ -48,7 +52,15 @@ func (s sortableResult) Swap(i, j int) {
 }
 
 func initLinguistAttributes(dir string) error {
-	ignore := []string{}
+	// paths excluded from the build context by .dockerignore or .draftignore are not detected either.
+	rules, err := ignore.ParseDir(dir)
+	if err != nil {
+		return err
+	}
+
+	walkIgnoredDirs = rules.Exceptions()
+
+	ignored := []string{}
 	except := []string{}
 	detected := make(map[string]string)
 
@@ -81,7 +93,7 @@ func initLinguistAttributes(dir string) error {
 			if isExcept {
 				except = append(except, p)
 			} else {
-				ignore = append(ignore, p)
+				ignored = append(ignored, p)
 			}
 		}
 		if err := ignoreScanner.Err(); err != nil {
@@ -120,7 +132,7 @@ func initLinguistAttributes(dir string) error {
 			attribute := words[1]
 			if strings.HasPrefix(attribute, "linguist-documentation") || strings.HasPrefix(attribute, "linguist-vendored") || strings.HasPrefix(attribute, "linguist-generated") {
 				if !strings.HasSuffix(strings.ToLower(attribute), "false") {
-					ignore = append(ignore, path)
+					ignored = append(ignored, path)
 				}
 			} else if strings.HasPrefix(attribute, "linguist-language") {
 				attr := strings.Split(attribute, "=")
@@ -138,12 +150,15 @@ func initLinguistAttributes(dir string) error {
 	}
 
 	isIgnored = func(filename string) bool {
-		for _, p := range ignore {
-			cleanPath, err := filepath.Rel(dir, filename)
-			if err != nil {
-				log.Debugf("could not get relative path: %v", err)
-				return false
-			}
+		cleanPath, err := filepath.Rel(dir, filename)
+		if err != nil {
+			log.Debugf("could not get relative path: %v", err)
+			return false
+		}
+		if rules.Ignore(cleanPath) {
+			return true
+		}
+		for _, p := range ignored {
 			if m, _ := filepath.Match(p, cleanPath); m {
 				for _, e := range except {
 					if m, _ := filepath.Match(e, cleanPath); m {
@@ -213,7 +228,7 @@ func ProcessDir(dirname string) ([]*Language, error) {
 		log.Debugln(path, "is", size, "bytes")
 		if isIgnored(path) {
 			log.Debugln(path, "is ignored, skipping")
-			if file.IsDir() {
+			if file.IsDir() && !walkIgnoredDirs {
 				return filepath.SkipDir
 			}
 			return nil
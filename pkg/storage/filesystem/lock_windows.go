@@ -0,0 +1,38 @@
+// +build windows
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+// LOCKFILE_EXCLUSIVE_LOCK from https://msdn.microsoft.com/en-us/library/windows/desktop/aa365203(v=vs.85).aspx
+const lockfileExclusiveLock = 0x00000002
+
+// lockFile acquires an exclusive lock on f, blocking until it is available.
+func lockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r1, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock held on f.
+func unlockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r1, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
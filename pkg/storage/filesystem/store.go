@@ -0,0 +1,248 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/draft/pkg/osutil"
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// Store represents a local filesystem storage engine for a storage.Object.
+//
+// The builds of each application are kept in a single JSON document under the
+// store's directory, mapping build IDs to the base64 encoded string of the
+// *storage.Object binary protobuf encoding. Access to an application's document
+// is serialized across draft processes by an advisory lock file.
+type Store struct {
+	dir string
+}
+
+// compile-time guarantee that *Store implements storage.Store
+var _ storage.Store = (*Store)(nil)
+
+// NewStore returns an implementation of storage.Store backed by files stored
+// under dir to store draft application build context.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DeleteBuilds deletes all draft builds for the application specified by appName.
+//
+// DeleteBuilds implements storage.Deleter.
+func (s *Store) DeleteBuilds(ctx context.Context, appName string) (builds []*storage.Object, err error) {
+	err = s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return storage.NewErrAppStorageNotFound(appName)
+		}
+		if builds, err = decodeAll(data); err != nil {
+			return err
+		}
+		return os.Remove(s.path(appName))
+	})
+	return builds, err
+}
+
+// DeleteBuild deletes the draft build given by buildID for the application specified by appName.
+//
+// DeleteBuild implements storage.Deleter.
+func (s *Store) DeleteBuild(ctx context.Context, appName, buildID string) (obj *storage.Object, err error) {
+	err = s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return storage.NewErrAppStorageNotFound(appName)
+		}
+		build, ok := data[buildID]
+		if !ok {
+			return storage.NewErrAppBuildNotFound(appName, buildID)
+		}
+		if obj, err = storage.DecodeString(build); err != nil {
+			return err
+		}
+		delete(data, buildID)
+		return s.write(appName, data)
+	})
+	return obj, err
+}
+
+// CreateBuild creates new storage for the application specified by appName to include build.
+//
+// If storage already exists for the application, ErrAppStorageExists is returned.
+//
+// CreateBuild implements storage.Creater.
+func (s *Store) CreateBuild(ctx context.Context, appName string, build *storage.Object) error {
+	return s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			return storage.NewErrAppStorageExists(appName)
+		}
+		return s.put(appName, make(map[string]string), build)
+	})
+}
+
+// UpdateBuild updates the application storage specified by appName to include build.
+//
+// If build does not exist, a new storage entry is created. Otherwise the existing storage
+// is updated.
+//
+// UpdateBuild implements storage.Updater.
+func (s *Store) UpdateBuild(ctx context.Context, appName string, build *storage.Object) error {
+	return s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			data = make(map[string]string)
+		}
+		if _, ok := data[build.BuildID]; ok {
+			return storage.NewErrAppBuildExists(appName, build.BuildID)
+		}
+		return s.put(appName, data, build)
+	})
+}
+
+// GetBuilds returns a slice of builds for the given app name.
+//
+// GetBuilds implements storage.Getter.
+func (s *Store) GetBuilds(ctx context.Context, appName string) (builds []*storage.Object, err error) {
+	err = s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return storage.NewErrAppStorageNotFound(appName)
+		}
+		builds, err = decodeAll(data)
+		return err
+	})
+	return builds, err
+}
+
+// GetBuild returns the build associated with buildID for the specified app name.
+//
+// GetBuild implements storage.Getter.
+func (s *Store) GetBuild(ctx context.Context, appName, buildID string) (obj *storage.Object, err error) {
+	err = s.withLock(appName, func() error {
+		data, err := s.read(appName)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return storage.NewErrAppStorageNotFound(appName)
+		}
+		build, ok := data[buildID]
+		if !ok {
+			return storage.NewErrAppBuildNotFound(appName, buildID)
+		}
+		obj, err = storage.DecodeString(build)
+		return err
+	})
+	return obj, err
+}
+
+// put stamps build with its creation time and writes it to the application's storage alongside data.
+func (s *Store) put(appName string, data map[string]string, build *storage.Object) (err error) {
+	if build.CreatedAt, err = ptypes.TimestampProto(time.Now()); err != nil {
+		return err
+	}
+	content, err := storage.EncodeToString(build)
+	if err != nil {
+		return err
+	}
+	data[build.BuildID] = content
+	return s.write(appName, data)
+}
+
+// path returns the path to the storage document for the application specified by appName.
+func (s *Store) path(appName string) string {
+	return filepath.Join(s.dir, appName+".json")
+}
+
+// withLock calls fn while holding the lock on the storage for the application specified by appName.
+func (s *Store) withLock(appName string, fn func() error) error {
+	if err := osutil.EnsureDirectory(s.dir); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, appName+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("could not lock storage for application %q: %v", appName, err)
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// read returns the encoded builds for the application specified by appName, keyed
+// by build ID. If no storage exists for the application, read returns a nil map.
+func (s *Store) read(appName string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(s.path(appName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data := make(map[string]string)
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("could not decode storage for application %q: %v", appName, err)
+	}
+	return data, nil
+}
+
+// write replaces the storage for the application specified by appName with data.
+//
+// The document is written to a temporary file first and then renamed into place
+// so that readers never observe a partially written document.
+func (s *Store) write(appName string, data map[string]string) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(s.dir, appName+".json.")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(appName))
+}
+
+// decodeAll decodes every build in data.
+func decodeAll(data map[string]string) (builds []*storage.Object, err error) {
+	for _, obj := range data {
+		build, err := storage.DecodeString(obj)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
@@ -0,0 +1,190 @@
+package filesystem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestStoreDeleteBuilds(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+	)
+	defer cleanup()
+	switch objs, err := store.DeleteBuilds(ctx, "app1"); {
+	case err != nil:
+		t.Fatalf("failed to delete builds: %v", err)
+	case len(objs) != 4:
+		t.Fatalf("expected 4 deleted builds, got %d", len(objs))
+	}
+	if _, err := store.GetBuilds(ctx, "app1"); err == nil {
+		t.Fatal("expected build storage to be deleted")
+	}
+}
+
+func TestStoreDeleteBuild(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+	)
+	defer cleanup()
+	obj, err := store.DeleteBuild(ctx, "app1", "foo4")
+	if err != nil {
+		t.Fatalf("failed to delete build: %v", err)
+	}
+	assertEqual(t, "DeleteBuild", obj.GetRelease(), "bar4")
+	if _, err := store.GetBuild(ctx, "app1", "foo4"); err == nil {
+		t.Fatal("expected build to be deleted")
+	}
+}
+
+func TestStoreCreateBuild(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+		obj            = objectStub("foo1", "bar1", []byte("foobar1"))
+	)
+	defer cleanup()
+	if err := store.CreateBuild(ctx, "app2", obj); err != nil {
+		t.Fatalf("failed to create build: %v", err)
+	}
+	got, err := store.GetBuild(ctx, "app2", "foo1")
+	if err != nil {
+		t.Fatalf("failed to get storage object: %v", err)
+	}
+	assertProtoEqual(t, "CreateBuild", got, obj)
+
+	// try creating a second time; this should fail with ErrAppStorageExists.
+	if err := store.CreateBuild(ctx, "app2", obj); err == nil {
+		t.Fatalf("expected second CreateBuild to fail")
+	}
+}
+
+func TestStoreUpdateBuild(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+		obj            = objectStub("foo5", "bar5", []byte("foobar5"))
+	)
+	defer cleanup()
+	if err := store.UpdateBuild(ctx, "app1", obj); err != nil {
+		t.Fatalf("failed to update build: %v", err)
+	}
+	got, err := store.GetBuild(ctx, "app1", "foo5")
+	if err != nil {
+		t.Fatalf("failed to get storage object: %v", err)
+	}
+	assertProtoEqual(t, "UpdateBuild", got, obj)
+
+	// updating with an existing build should fail with ErrAppBuildExists.
+	if err := store.UpdateBuild(ctx, "app1", obj); err == nil {
+		t.Fatalf("expected second UpdateBuild to fail")
+	}
+}
+
+func TestStoreUpdateBuildConcurrent(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+		ids            = []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+		wg             sync.WaitGroup
+	)
+	defer cleanup()
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			// use a separate store for each writer, as separate draft processes would.
+			if err := NewStore(store.dir).UpdateBuild(ctx, "app3", objectStub(id, id, []byte(id))); err != nil {
+				t.Errorf("failed to update build %q: %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+	switch got, err := store.GetBuilds(ctx, "app3"); {
+	case err != nil:
+		t.Fatalf("failed to get builds: %v", err)
+	case len(got) != len(ids):
+		t.Fatalf("expected %d storage objects, got %d", len(ids), len(got))
+	}
+}
+
+func TestStoreGetBuilds(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+	)
+	defer cleanup()
+	switch got, err := store.GetBuilds(ctx, "app1"); {
+	case err != nil:
+		t.Fatalf("failed to get builds: %v", err)
+	case len(got) != 4:
+		t.Fatalf("expected 4 storage objects, got %d", len(got))
+	}
+	// try fetching builds with an unknown app name; should fail.
+	if alt, err := store.GetBuilds(ctx, "bad"); err == nil {
+		t.Fatalf("want err != nil; got alt: %+v", alt)
+	}
+}
+
+func TestStoreGetBuild(t *testing.T) {
+	var (
+		store, cleanup = newStoreTestFixture(t)
+		ctx            = context.Background()
+	)
+	defer cleanup()
+	got, err := store.GetBuild(ctx, "app1", "foo1")
+	if err != nil {
+		t.Fatalf("failed to get storage object: %v", err)
+	}
+	assertEqual(t, "GetBuild", got.GetRelease(), "bar1")
+	// try fetching a build with an unknown app name; should fail.
+	if alt, err := store.GetBuild(ctx, "bad", ""); err == nil {
+		t.Fatalf("want err != nil; got alt: %+v", alt)
+	}
+}
+
+//
+// test fixtures / helpers
+//
+
+func newStoreTestFixture(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "draft-storage")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	store := NewStore(dir)
+	for i, id := range []string{"foo1", "foo2", "foo3", "foo4"} {
+		obj := objectStub(id, "bar"+id[3:], []byte("foobar"+id[3:]))
+		if err := store.UpdateBuild(context.Background(), "app1", obj); err != nil {
+			t.Fatalf("failed to store build %d: %v", i, err)
+		}
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func objectStub(buildID, release string, contextID []byte) *storage.Object {
+	return &storage.Object{
+		BuildID:   buildID,
+		Release:   release,
+		ContextID: contextID,
+	}
+}
+
+func assertEqual(t *testing.T, label string, a, b interface{}) {
+	if a != b {
+		t.Errorf("failed equality for %s: %v != %v", label, a, b)
+	}
+}
+
+func assertProtoEqual(t *testing.T, label string, a, b proto.Message) {
+	if !proto.Equal(a, b) {
+		t.Errorf("failed equality for %s", label)
+	}
+}
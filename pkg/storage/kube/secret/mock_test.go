@@ -0,0 +1,96 @@
+package secret
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/testapigroup"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/Azure/draft/pkg/storage"
+)
+
+// MockSecrets mocks a kubernetes SecretsInterface.
+//
+// For use in testing only.
+type MockSecrets struct {
+	corev1.SecretInterface
+	secrets map[string]*v1.Secret
+}
+
+// NewSecretsWithMocks initializes a new Secrets store initialized
+// with kubernetes Secret objects created from the provided entries.
+func NewSecretsWithMocks(t *testing.T, entries ...struct {
+	appName string
+	objects []*storage.Object
+}) *Secrets {
+	var mock MockSecrets
+	mock.Init(t, entries...)
+	return NewSecrets(&mock)
+}
+
+// Init initializes the MockSecrets mock with the set of storage objects.
+func (mock *MockSecrets) Init(t *testing.T, entries ...struct {
+	appName string
+	objects []*storage.Object
+}) {
+	mock.secrets = make(map[string]*v1.Secret)
+	for _, entry := range entries {
+		var secret *v1.Secret
+		for _, object := range entry.objects {
+			if secret != nil {
+				if _, ok := secret.Data[object.BuildID]; !ok {
+					content, err := storage.EncodeToString(object)
+					if err != nil {
+						t.Fatalf("failed to encode storage object: %v", err)
+					}
+					secret.Data[object.BuildID] = []byte(content)
+				}
+			} else {
+				var err error
+				if secret, err = newSecret(entry.appName, object); err != nil {
+					t.Fatalf("failed to create secret: %v", err)
+				}
+			}
+		}
+		mock.secrets[entry.appName] = secret
+	}
+}
+
+// Get returns the Secret by name.
+func (mock *MockSecrets) Get(name string, options metav1.GetOptions) (*v1.Secret, error) {
+	secret, ok := mock.secrets[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(testapigroup.Resource("tests"), name)
+	}
+	return secret, nil
+}
+
+// Create creates a new Secret.
+func (mock *MockSecrets) Create(secret *v1.Secret) (*v1.Secret, error) {
+	name := secret.ObjectMeta.Name
+	if object, ok := mock.secrets[name]; ok {
+		return object, apierrors.NewAlreadyExists(testapigroup.Resource("tests"), name)
+	}
+	mock.secrets[name] = secret
+	return secret, nil
+}
+
+// Update updates a Secret.
+func (mock *MockSecrets) Update(secret *v1.Secret) (*v1.Secret, error) {
+	name := secret.ObjectMeta.Name
+	mock.secrets[name] = secret
+	return secret, nil
+}
+
+// Delete deletes a Secret by name.
+func (mock *MockSecrets) Delete(name string, opts *metav1.DeleteOptions) error {
+	if _, ok := mock.secrets[name]; !ok {
+		return apierrors.NewNotFound(testapigroup.Resource("tests"), name)
+	}
+	delete(mock.secrets, name)
+	return nil
+}
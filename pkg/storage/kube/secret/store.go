@@ -0,0 +1,223 @@
+package secret
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/draft/pkg/storage"
+	"github.com/golang/protobuf/ptypes"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Secrets represents a Kubernetes secret storage engine for a storage.Object .
+type Secrets struct {
+	impl corev1.SecretInterface
+}
+
+// compile-time guarantee that *Secrets implements storage.Store
+var _ storage.Store = (*Secrets)(nil)
+
+// NewSecrets returns an implementation of storage.Store backed by kubernetes
+// Secret objects to store draft application build context.
+func NewSecrets(impl corev1.SecretInterface) *Secrets {
+	return &Secrets{impl}
+}
+
+// DeleteBuilds deletes all draft builds for the application specified by appName.
+//
+// DeleteBuilds implements storage.Deleter.
+func (s *Secrets) DeleteBuilds(ctx context.Context, appName string) ([]*storage.Object, error) {
+	builds, err := s.GetBuilds(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	err = s.impl.Delete(appName, &metav1.DeleteOptions{})
+	return builds, err
+}
+
+// DeleteBuild deletes the draft build given by buildID for the application specified by appName.
+//
+// DeleteBuild implements storage.Deleter.
+func (s *Secrets) DeleteBuild(ctx context.Context, appName, buildID string) (obj *storage.Object, err error) {
+	var secret *v1.Secret
+	if secret, err = s.impl.Get(appName, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, storage.NewErrAppStorageNotFound(appName)
+		}
+		return nil, err
+	}
+	if build, ok := secret.Data[buildID]; ok {
+		if obj, err = storage.DecodeString(string(build)); err != nil {
+			return nil, err
+		}
+		delete(secret.Data, buildID)
+		_, err = s.impl.Update(secret)
+		return obj, err
+	}
+	return nil, storage.NewErrAppBuildNotFound(appName, buildID)
+}
+
+// CreateBuild creates new storage for the application specified by appName to include build.
+//
+// If the secret storage already exists for the application, ErrAppStorageExists is returned.
+//
+// CreateBuild implements storage.Creater.
+func (s *Secrets) CreateBuild(ctx context.Context, appName string, build *storage.Object) error {
+	now, err := ptypes.TimestampProto(time.Now())
+	if err != nil {
+		return err
+	}
+	build.CreatedAt = now
+
+	secret, err := newSecret(appName, build)
+	if err != nil {
+		return err
+	}
+	if _, err = s.impl.Create(secret); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return storage.NewErrAppStorageExists(appName)
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateBuild updates the application secret storage specified by appName to include build.
+//
+// If build does not exist, a new storage entry is created. Otherwise the existing storage
+// is updated.
+//
+// UpdateBuild implements storage.Updater.
+func (s *Secrets) UpdateBuild(ctx context.Context, appName string, build *storage.Object) (err error) {
+	var secret *v1.Secret
+	if secret, err = s.impl.Get(appName, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return s.CreateBuild(ctx, appName, build)
+		}
+		return err
+	}
+	if _, ok := secret.Data[build.BuildID]; ok {
+		return storage.NewErrAppBuildExists(appName, build.BuildID)
+	}
+	if build.CreatedAt, err = ptypes.TimestampProto(time.Now()); err != nil {
+		return err
+	}
+	content, err := storage.EncodeToString(build)
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[build.BuildID] = []byte(content)
+	_, err = s.impl.Update(secret)
+	return err
+}
+
+// ImportBuilds adds builds to the application secret storage specified by appName,
+// creating the storage if it does not exist yet.
+//
+// Unlike UpdateBuild, the builds keep their original creation timestamps so that
+// history migrated from another storage engine retains its order. Builds which are
+// already stored are left untouched.
+func (s *Secrets) ImportBuilds(ctx context.Context, appName string, builds []*storage.Object) error {
+	secret, err := s.impl.Get(appName, metav1.GetOptions{})
+	exists := err == nil
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		secret = newSecretObject(appName)
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	for _, build := range builds {
+		if _, ok := secret.Data[build.BuildID]; ok {
+			continue
+		}
+		content, err := storage.EncodeToString(build)
+		if err != nil {
+			return err
+		}
+		secret.Data[build.BuildID] = []byte(content)
+	}
+	if exists {
+		_, err = s.impl.Update(secret)
+	} else {
+		_, err = s.impl.Create(secret)
+	}
+	return err
+}
+
+// GetBuilds returns a slice of builds for the given app name.
+//
+// GetBuilds implements storage.Getter.
+func (s *Secrets) GetBuilds(ctx context.Context, appName string) (builds []*storage.Object, err error) {
+	var secret *v1.Secret
+	if secret, err = s.impl.Get(appName, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, storage.NewErrAppStorageNotFound(appName)
+		}
+		return nil, err
+	}
+	for _, obj := range secret.Data {
+		build, err := storage.DecodeString(string(obj))
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+// GetBuild returns the build associated with buildID for the specified app name.
+//
+// GetBuild implements storage.Getter.
+func (s *Secrets) GetBuild(ctx context.Context, appName, buildID string) (obj *storage.Object, err error) {
+	var secret *v1.Secret
+	if secret, err = s.impl.Get(appName, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, storage.NewErrAppStorageNotFound(appName)
+		}
+		return nil, err
+	}
+	if data, ok := secret.Data[buildID]; ok {
+		if obj, err = storage.DecodeString(string(data)); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+	return nil, storage.NewErrAppBuildNotFound(appName, buildID)
+}
+
+// newSecret constructs a kubernetes Secret object to store a build.
+//
+// Each secret data entry is the base64 encoded string of a *storage.Object
+// binary protobuf encoding, the same encoding used by the configmap storage engine.
+func newSecret(appName string, build *storage.Object) (*v1.Secret, error) {
+	content, err := storage.EncodeToString(build)
+	if err != nil {
+		return nil, err
+	}
+	secret := newSecretObject(appName)
+	secret.Data = map[string][]byte{build.BuildID: []byte(content)}
+	return secret, nil
+}
+
+// newSecretObject constructs an empty kubernetes Secret object for the application specified by appName.
+func newSecretObject(appName string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appName,
+			Labels: map[string]string{
+				"heritage": "draft",
+				"appname":  appName,
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+}
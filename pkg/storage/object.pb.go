@@ -9,13 +9,15 @@ It is generated from these files:
 
 It has these top-level messages:
 	Object
+	Stage
 */
 package storage
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
-import google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
+import google_protobuf "github.com/golang/protobuf/ptypes/duration"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/timestamp"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -30,11 +32,25 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 // Object is the storage object for a draft applications build history.
 type Object struct {
-	BuildID     string                     `protobuf:"bytes,1,opt,name=buildID" json:"buildID,omitempty"`
-	Release     string                     `protobuf:"bytes,2,opt,name=release" json:"release,omitempty"`
-	ContextID   []byte                     `protobuf:"bytes,3,opt,name=contextID,proto3" json:"contextID,omitempty"`
-	LogsFileRef string                     `protobuf:"bytes,4,opt,name=logs_file_ref,json=logsFileRef" json:"logs_file_ref,omitempty"`
-	CreatedAt   *google_protobuf.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+	BuildID            string                      `protobuf:"bytes,1,opt,name=buildID" json:"buildID,omitempty"`
+	Release            string                      `protobuf:"bytes,2,opt,name=release" json:"release,omitempty"`
+	ContextID          []byte                      `protobuf:"bytes,3,opt,name=contextID,proto3" json:"contextID,omitempty"`
+	LogsFileRef        string                      `protobuf:"bytes,4,opt,name=logs_file_ref,json=logsFileRef" json:"logs_file_ref,omitempty"`
+	CreatedAt          *google_protobuf1.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+	Images             []string                    `protobuf:"bytes,6,rep,name=images" json:"images,omitempty"`
+	Environment        string                      `protobuf:"bytes,7,opt,name=environment" json:"environment,omitempty"`
+	VcsRevision        string                      `protobuf:"bytes,8,opt,name=vcs_revision,json=vcsRevision" json:"vcs_revision,omitempty"`
+	VcsDirty           bool                        `protobuf:"varint,9,opt,name=vcs_dirty,json=vcsDirty" json:"vcs_dirty,omitempty"`
+	Stages             []*Stage                    `protobuf:"bytes,10,rep,name=stages" json:"stages,omitempty"`
+	Status             string                      `protobuf:"bytes,11,opt,name=status" json:"status,omitempty"`
+	ErrorMessage       string                      `protobuf:"bytes,12,opt,name=error_message,json=errorMessage" json:"error_message,omitempty"`
+	FailedStage        string                      `protobuf:"bytes,13,opt,name=failed_stage,json=failedStage" json:"failed_stage,omitempty"`
+	ReleaseVersion     int32                       `protobuf:"varint,14,opt,name=release_version,json=releaseVersion" json:"release_version,omitempty"`
+	RollbackTo         string                      `protobuf:"bytes,15,opt,name=rollback_to,json=rollbackTo" json:"rollback_to,omitempty"`
+	ImageArchiveRef    string                      `protobuf:"bytes,16,opt,name=image_archive_ref,json=imageArchiveRef" json:"image_archive_ref,omitempty"`
+	ImageArchiveDigest string                      `protobuf:"bytes,17,opt,name=image_archive_digest,json=imageArchiveDigest" json:"image_archive_digest,omitempty"`
+	ImageDigest        string                      `protobuf:"bytes,18,opt,name=image_digest,json=imageDigest" json:"image_digest,omitempty"`
+	ImageDigests       map[string]string           `protobuf:"bytes,19,rep,name=image_digests,json=imageDigests" json:"image_digests,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *Object) Reset()                    { *m = Object{} }
@@ -70,32 +86,186 @@ func (m *Object) GetLogsFileRef() string {
 	return ""
 }
 
-func (m *Object) GetCreatedAt() *google_protobuf.Timestamp {
+func (m *Object) GetCreatedAt() *google_protobuf1.Timestamp {
 	if m != nil {
 		return m.CreatedAt
 	}
 	return nil
 }
 
+func (m *Object) GetImages() []string {
+	if m != nil {
+		return m.Images
+	}
+	return nil
+}
+
+func (m *Object) GetEnvironment() string {
+	if m != nil {
+		return m.Environment
+	}
+	return ""
+}
+
+func (m *Object) GetVcsRevision() string {
+	if m != nil {
+		return m.VcsRevision
+	}
+	return ""
+}
+
+func (m *Object) GetVcsDirty() bool {
+	if m != nil {
+		return m.VcsDirty
+	}
+	return false
+}
+
+func (m *Object) GetStages() []*Stage {
+	if m != nil {
+		return m.Stages
+	}
+	return nil
+}
+
+func (m *Object) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Object) GetErrorMessage() string {
+	if m != nil {
+		return m.ErrorMessage
+	}
+	return ""
+}
+
+func (m *Object) GetFailedStage() string {
+	if m != nil {
+		return m.FailedStage
+	}
+	return ""
+}
+
+func (m *Object) GetReleaseVersion() int32 {
+	if m != nil {
+		return m.ReleaseVersion
+	}
+	return 0
+}
+
+func (m *Object) GetRollbackTo() string {
+	if m != nil {
+		return m.RollbackTo
+	}
+	return ""
+}
+
+func (m *Object) GetImageArchiveRef() string {
+	if m != nil {
+		return m.ImageArchiveRef
+	}
+	return ""
+}
+
+func (m *Object) GetImageArchiveDigest() string {
+	if m != nil {
+		return m.ImageArchiveDigest
+	}
+	return ""
+}
+
+func (m *Object) GetImageDigest() string {
+	if m != nil {
+		return m.ImageDigest
+	}
+	return ""
+}
+
+func (m *Object) GetImageDigests() map[string]string {
+	if m != nil {
+		return m.ImageDigests
+	}
+	return nil
+}
+
+// Stage records the outcome of a single draft up stage.
+type Stage struct {
+	Name     string                    `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Status   string                    `protobuf:"bytes,2,opt,name=status" json:"status,omitempty"`
+	Duration *google_protobuf.Duration `protobuf:"bytes,3,opt,name=duration" json:"duration,omitempty"`
+}
+
+func (m *Stage) Reset()                    { *m = Stage{} }
+func (m *Stage) String() string            { return proto.CompactTextString(m) }
+func (*Stage) ProtoMessage()               {}
+func (*Stage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Stage) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Stage) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Stage) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Object)(nil), "storage.Object")
+	proto.RegisterType((*Stage)(nil), "storage.Stage")
 }
 
 func init() { proto.RegisterFile("object.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 208 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x44, 0x8e, 0xbd, 0x6a, 0xc3, 0x30,
-	0x14, 0x85, 0x51, 0x7f, 0x12, 0xac, 0xa4, 0x8b, 0x26, 0x11, 0x0a, 0x35, 0x99, 0x3c, 0x29, 0xd0,
-	0x4e, 0x1d, 0x0b, 0xa1, 0x90, 0xa9, 0x20, 0xba, 0x1b, 0xc9, 0xb9, 0x12, 0x2a, 0x72, 0xaf, 0x91,
-	0xae, 0xa1, 0xaf, 0xd6, 0xb7, 0x2b, 0x96, 0x6d, 0x3a, 0x9e, 0xf3, 0x9d, 0x03, 0x1f, 0xdf, 0xa3,
-	0xfd, 0x82, 0x8e, 0xd4, 0x90, 0x90, 0x50, 0x6c, 0x33, 0x61, 0x32, 0x1e, 0x0e, 0x4f, 0x1e, 0xd1,
-	0x47, 0x38, 0x95, 0xda, 0x8e, 0xee, 0x44, 0xa1, 0x87, 0x4c, 0xa6, 0x1f, 0xe6, 0xe5, 0xf1, 0x97,
-	0xf1, 0xcd, 0x47, 0xb9, 0x0a, 0xc9, 0xb7, 0x76, 0x0c, 0xf1, 0x7a, 0x39, 0x4b, 0x56, 0xb3, 0xa6,
-	0xd2, 0x6b, 0x9c, 0x48, 0x82, 0x08, 0x26, 0x83, 0xbc, 0x99, 0xc9, 0x12, 0xc5, 0x23, 0xaf, 0x3a,
-	0xfc, 0x26, 0xf8, 0xa1, 0xcb, 0x59, 0xde, 0xd6, 0xac, 0xd9, 0xeb, 0xff, 0x42, 0x1c, 0xf9, 0x43,
-	0x44, 0x9f, 0x5b, 0x17, 0x22, 0xb4, 0x09, 0x9c, 0xbc, 0x2b, 0xef, 0xdd, 0x54, 0xbe, 0x87, 0x08,
-	0x1a, 0x9c, 0x78, 0xe5, 0xbc, 0x4b, 0x60, 0x08, 0xae, 0xad, 0x21, 0x79, 0x5f, 0xb3, 0x66, 0xf7,
-	0x7c, 0x50, 0xb3, 0xb6, 0x5a, 0xb5, 0xd5, 0xe7, 0xaa, 0xad, 0xab, 0x65, 0xfd, 0x46, 0x76, 0x53,
-	0xf0, 0xcb, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa7, 0xa5, 0xc3, 0xdf, 0xfc, 0x00, 0x00, 0x00,
+	// 546 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x93, 0x4f, 0x6f, 0xd4, 0x3c,
+	0x10, 0xc6, 0x95, 0x6e, 0x37, 0xdd, 0x4c, 0xb2, 0xfd, 0xe3, 0xb7, 0x7a, 0x65, 0x16, 0x44, 0xd3,
+	0x22, 0x41, 0xc4, 0x21, 0x45, 0x45, 0x48, 0xc0, 0x05, 0x55, 0x5a, 0x2a, 0xed, 0x01, 0x21, 0x85,
+	0x8a, 0x6b, 0xe4, 0x4d, 0x66, 0x83, 0xdb, 0x24, 0xae, 0x6c, 0x6f, 0xc4, 0x7e, 0x0a, 0xbe, 0x32,
+	0x8a, 0xed, 0xb4, 0x5b, 0x7a, 0xcb, 0x3c, 0xcf, 0xcf, 0x9e, 0xf1, 0xcc, 0x04, 0x22, 0xb1, 0xbc,
+	0xc1, 0x42, 0xa7, 0x77, 0x52, 0x68, 0x41, 0xf6, 0x94, 0x16, 0x92, 0x55, 0x38, 0x7b, 0x59, 0x09,
+	0x51, 0xd5, 0x78, 0x6e, 0xe4, 0xe5, 0x7a, 0x75, 0x5e, 0xae, 0x25, 0xd3, 0x5c, 0xb4, 0x16, 0x9c,
+	0x9d, 0xfc, 0xeb, 0x6b, 0xde, 0xa0, 0xd2, 0xac, 0xb9, 0xb3, 0xc0, 0xd9, 0x1f, 0x1f, 0xfc, 0xef,
+	0xe6, 0x6a, 0x42, 0x61, 0x6f, 0xb9, 0xe6, 0x75, 0xb9, 0x98, 0x53, 0x2f, 0xf6, 0x92, 0x20, 0x1b,
+	0xc2, 0xde, 0x91, 0x58, 0x23, 0x53, 0x48, 0x77, 0xac, 0xe3, 0x42, 0xf2, 0x02, 0x82, 0x42, 0xb4,
+	0x1a, 0x7f, 0xeb, 0xc5, 0x9c, 0x8e, 0x62, 0x2f, 0x89, 0xb2, 0x07, 0x81, 0x9c, 0xc1, 0xb4, 0x16,
+	0x95, 0xca, 0x57, 0xbc, 0xc6, 0x5c, 0xe2, 0x8a, 0xee, 0x9a, 0xd3, 0x61, 0x2f, 0x5e, 0xf1, 0x1a,
+	0x33, 0x5c, 0x91, 0x4f, 0x00, 0x85, 0x44, 0xa6, 0xb1, 0xcc, 0x99, 0xa6, 0xe3, 0xd8, 0x4b, 0xc2,
+	0x8b, 0x59, 0x6a, 0xcb, 0x4e, 0x87, 0xb2, 0xd3, 0xeb, 0xa1, 0xec, 0x2c, 0x70, 0xf4, 0xa5, 0x26,
+	0xff, 0x83, 0xcf, 0x1b, 0x56, 0xa1, 0xa2, 0x7e, 0x3c, 0x4a, 0x82, 0xcc, 0x45, 0x24, 0x86, 0x10,
+	0xdb, 0x8e, 0x4b, 0xd1, 0x36, 0xd8, 0x6a, 0xba, 0x67, 0x93, 0x6e, 0x49, 0xe4, 0x14, 0xa2, 0xae,
+	0x50, 0xb9, 0xc4, 0x8e, 0x2b, 0x2e, 0x5a, 0x3a, 0xb1, 0x48, 0x57, 0xa8, 0xcc, 0x49, 0xe4, 0x39,
+	0x04, 0x3d, 0x52, 0x72, 0xa9, 0x37, 0x34, 0x88, 0xbd, 0x64, 0x92, 0x4d, 0xba, 0x42, 0xcd, 0xfb,
+	0x98, 0xbc, 0x06, 0x5f, 0x69, 0x93, 0x19, 0xe2, 0x51, 0x12, 0x5e, 0xec, 0xa7, 0x6e, 0x20, 0xe9,
+	0x8f, 0x5e, 0xce, 0x9c, 0xdb, 0x57, 0xa8, 0x34, 0xd3, 0x6b, 0x45, 0x43, 0x93, 0xc1, 0x45, 0xe4,
+	0x15, 0x4c, 0x51, 0x4a, 0x21, 0xf3, 0x06, 0x95, 0x62, 0x15, 0xd2, 0xc8, 0xd8, 0x91, 0x11, 0xbf,
+	0x59, 0xad, 0x2f, 0x72, 0xc5, 0x78, 0x8d, 0x65, 0x6e, 0x6e, 0xa3, 0x53, 0x5b, 0xa4, 0xd5, 0x4c,
+	0x1e, 0xf2, 0x06, 0x0e, 0xdc, 0x24, 0xf2, 0x0e, 0xa5, 0x79, 0xca, 0x7e, 0xec, 0x25, 0xe3, 0x6c,
+	0xdf, 0xc9, 0x3f, 0xad, 0x4a, 0x4e, 0x20, 0x94, 0xa2, 0xae, 0x97, 0xac, 0xb8, 0xcd, 0xb5, 0xa0,
+	0x07, 0xe6, 0x2a, 0x18, 0xa4, 0x6b, 0x41, 0xde, 0xc2, 0x91, 0xe9, 0x5e, 0xce, 0x64, 0xf1, 0x8b,
+	0x77, 0x76, 0x5c, 0x87, 0x06, 0x3b, 0x30, 0xc6, 0xa5, 0xd5, 0xfb, 0x91, 0xbd, 0x83, 0xe3, 0xc7,
+	0x6c, 0xc9, 0x2b, 0x54, 0x9a, 0x1e, 0x19, 0x9c, 0x6c, 0xe3, 0x73, 0xe3, 0xf4, 0x4f, 0xb1, 0x27,
+	0x1c, 0x49, 0xec, 0x53, 0x8c, 0xe6, 0x90, 0x2b, 0x98, 0x6e, 0x23, 0x8a, 0xfe, 0x67, 0x3a, 0x7b,
+	0x7a, 0xdf, 0x59, 0xbb, 0xa5, 0xe9, 0xe2, 0xe1, 0x8c, 0xfa, 0xda, 0x6a, 0xb9, 0xc9, 0xa2, 0xad,
+	0x6b, 0xd4, 0xec, 0x0b, 0x1c, 0x3d, 0x41, 0xc8, 0x21, 0x8c, 0x6e, 0x71, 0xe3, 0xd6, 0xba, 0xff,
+	0x24, 0xc7, 0x30, 0xee, 0x58, 0xbd, 0x1e, 0x16, 0xda, 0x06, 0x9f, 0x77, 0x3e, 0x7a, 0x67, 0x37,
+	0x30, 0xb6, 0xcd, 0x25, 0xb0, 0xdb, 0xb2, 0x06, 0xdd, 0x29, 0xf3, 0xbd, 0x35, 0xd0, 0x9d, 0x47,
+	0x03, 0xfd, 0x00, 0x93, 0xe1, 0xcf, 0x33, 0xbf, 0x41, 0x78, 0xf1, 0xec, 0xc9, 0x0e, 0xcf, 0x1d,
+	0x90, 0xdd, 0xa3, 0x4b, 0xdf, 0x98, 0xef, 0xff, 0x0e, 0x00, 0xf8, 0x42, 0xa0, 0xd1, 0xde, 0x03,
+	0x00, 0x00,
 }
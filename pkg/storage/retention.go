@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+// RetentionPolicy limits how much build history is kept for an application.
+//
+// The zero value keeps every build.
+type RetentionPolicy struct {
+	// MaxBuilds is the number of most recent builds to keep. Zero means no limit.
+	MaxBuilds int
+	// MaxAge is how long a build is kept after it was created. Zero means no limit.
+	MaxAge time.Duration
+}
+
+// Expired returns the builds which the policy no longer retains at the given time.
+func (p RetentionPolicy) Expired(builds []*Object, now time.Time) (expired []*Object) {
+	sorted := make([]*Object, len(builds))
+	copy(sorted, builds)
+	SortByCreatedAt(sorted)
+	for i, build := range sorted {
+		if p.MaxBuilds > 0 && len(sorted)-i > p.MaxBuilds {
+			expired = append(expired, build)
+			continue
+		}
+		if p.MaxAge > 0 {
+			created, err := ptypes.Timestamp(build.GetCreatedAt())
+			if err == nil && now.Sub(created) > p.MaxAge {
+				expired = append(expired, build)
+			}
+		}
+	}
+	return expired
+}
+
+// Prune deletes the builds of the application specified by appName which are
+// no longer retained by policy, returning the deleted builds.
+func Prune(ctx context.Context, store Store, appName string, policy RetentionPolicy) ([]*Object, error) {
+	if policy.MaxBuilds <= 0 && policy.MaxAge <= 0 {
+		return nil, nil
+	}
+	builds, err := store.GetBuilds(ctx, appName)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []*Object
+	for _, build := range policy.Expired(builds, time.Now()) {
+		obj, err := store.DeleteBuild(ctx, appName, build.GetBuildID())
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, obj)
+	}
+	return deleted, nil
+}
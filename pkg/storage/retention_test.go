@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Now()
+	build := func(id string, age time.Duration) *Object {
+		created, _ := ptypes.TimestampProto(now.Add(-age))
+		return &Object{BuildID: id, CreatedAt: created}
+	}
+	builds := []*Object{
+		build("foo3", 3*time.Hour),
+		build("foo1", 72*time.Hour),
+		build("foo4", 1*time.Hour),
+		build("foo2", 48*time.Hour),
+	}
+	var tests = []struct {
+		policy   RetentionPolicy
+		expected []string
+	}{
+		{RetentionPolicy{}, nil},
+		{RetentionPolicy{MaxBuilds: 2}, []string{"foo1", "foo2"}},
+		{RetentionPolicy{MaxBuilds: 10}, nil},
+		{RetentionPolicy{MaxAge: 24 * time.Hour}, []string{"foo1", "foo2"}},
+		{RetentionPolicy{MaxBuilds: 3, MaxAge: 60 * time.Hour}, []string{"foo1"}},
+		{RetentionPolicy{MaxBuilds: 3, MaxAge: 2 * time.Hour}, []string{"foo1", "foo2", "foo3"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, obj := range tt.policy.Expired(builds, now) {
+			got = append(got, obj.BuildID)
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%+v: expected %v to expire, got %v", tt.policy, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%+v: expected %v to expire, got %v", tt.policy, tt.expected, got)
+				break
+			}
+		}
+	}
+}